@@ -18,12 +18,19 @@ func main() {
 	}
 
 	command := os.Args[1]
+	cli.Version = version
 
 	switch command {
 	case "file":
 		cli.HandleFileCommand(os.Args[2:])
 	case "docker":
 		cli.HandleDockerCommand(os.Args[2:])
+	case "tui":
+		cli.HandleTUICommand(os.Args[2:])
+	case "parse-test":
+		cli.HandleParseTestCommand(os.Args[2:])
+	case "export":
+		cli.HandleExportCommand(os.Args[2:])
 	case "version", "-v", "--version":
 		fmt.Printf("shepai %s\n", version)
 		os.Exit(0)
@@ -43,13 +50,31 @@ func printUsage() {
 Usage:
   shepai file <path>     Stream logs from a file
   shepai docker <container>  Stream logs from a Docker container
+  shepai tui file|docker <source>  Browse logs in a full-screen terminal viewer
+  shepai parse-test <rule> <sample-file>  Show how a parser rule parses each line
+  shepai export [flags]  Save retained events from a running shepai (--query, --from, --to, --format ndjson|csv|txt, -o file)
 
 Flags:
   --port <number>        Port for web dashboard (default: 4040)
+  --config <path>        Config file with parser rules (default: ./shepai.json if present)
+  --tz <zone>            Timezone for timestamps without an offset, e.g. Asia/Tokyo (default: UTC)
+  --ansi <mode>          ANSI colors: spans (keep colors) or strip (default: spans)
+  --encoding <name>      Character encoding of a log file, e.g. shift_jis (default: utf-8)
+  --store <dir>          Keep log history on disk for scroll-back and restarts (default: memory only)
+  --no-ui, --stdout      Print logs to the terminal instead of serving the dashboard
+  --filter <query>       With --no-ui or tui, show only matching events, e.g. level:error
+  --output <format>      With --no-ui, print text (default) or json
+  --metrics-only         Serve only Prometheus metrics at /metrics, without the dashboard
 
 Examples:
   shepai file storage/logs/laravel.log
   shepai docker my_container --port 8080
+  shepai file storage/logs/laravel.log --tz Asia/Tokyo
+  shepai file storage/logs/laravel.log --no-ui --filter level:error
+  shepai tui docker my_container
+  shepai docker my_container --metrics-only --port 9400
+  shepai parse-test laravel storage/logs/laravel.log
+  shepai export --query level:error --from 1h --format txt -o errors.log
 
 `)
 }
@@ -0,0 +1,5 @@
+package main
+
+// Windows has no system timezone database, so embed Go's copy to make
+// --tz and per-source timezones work there too
+import _ "time/tzdata"
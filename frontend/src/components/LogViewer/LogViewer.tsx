@@ -1,17 +1,23 @@
-import { useEffect, useMemo, useRef, useState } from 'react'
-import type { LogEvent, WebSocketMessage } from '../../types/log'
+import { useEffect, useLayoutEffect, useMemo, useRef, useState } from 'react'
+import type { Bookmark, LogEvent, PauseMessage, SourceStatus, StatusEvent, WebSocketMessage } from '../../types/log'
 import { getStorageItem } from '../../lib/utils'
-import { Search } from 'lucide-react'
+import { addBookmark, fetchCorrelated, fetchEvents, fetchStatus, removeBookmark, session } from '../../lib/api'
+import { AlertTriangle, Bookmark as BookmarkIcon, History, Info, Link2, Pause, Search, X, XCircle } from 'lucide-react'
 import { createAnsiConverter } from './utils/ansi'
 import { groupLogEventsForDisplay } from './utils/logGrouping'
-import { getSeverityLevel } from './utils/severity'
-import type { LogLevelCounts } from './types'
+import { resolveSeverityLevel } from './utils/severity'
+import type { CorrelationFilter, LogLevelCounts, PauseSummary } from './types'
 import type { LogLevel } from './enums'
 import { LogLevel as LogLevelEnum } from './enums'
 import { LogViewerHeader } from './components/LogViewerHeader'
 import { LogViewerFooter } from './components/LogViewerFooter'
 import { LogViewerList } from './components/LogViewerList'
 
+// currentStatuses keeps the statuses still worth a banner when connecting:
+// warnings and errors, which last until the next status of their source
+const currentStatuses = (statuses: StatusEvent[] = []): Record<string, StatusEvent> =>
+  Object.fromEntries(statuses.filter((status) => status.severity !== 'info').map((status) => [status.source, status]))
+
 interface LogViewerProps {}
 
 export default function LogViewer({}: LogViewerProps) {
@@ -19,6 +25,7 @@ export default function LogViewer({}: LogViewerProps) {
   const [isPaused, setIsPaused] = useState(false)
   const [searchQuery, setSearchQuery] = useState('')
   const [showTimestamps, setShowTimestamps] = useState(() => getStorageItem('logViewer.showTimestamps', true))
+  const [showSourceTime, setShowSourceTime] = useState(() => getStorageItem('logViewer.showSourceTime', false))
   const [stackTraceViewEnabled, setStackTraceViewEnabled] = useState(() => getStorageItem('logViewer.stackTraceViewEnabled', false))
   const [autoScroll, setAutoScroll] = useState(() => getStorageItem('logViewer.autoScroll', true))
   const [connected, setConnected] = useState(false)
@@ -31,12 +38,26 @@ export default function LogViewer({}: LogViewerProps) {
   const [focusedLogKey, setFocusedLogKey] = useState<string | null>(null)
   const [zoomLevel, setZoomLevel] = useState(() => getStorageItem('logViewer.zoomLevel', 1)) // Default zoom level (1 = 100%)
   const [isDarkMode, setIsDarkMode] = useState(() => getStorageItem('darkMode', true))
+  const [correlation, setCorrelation] = useState<CorrelationFilter | null>(null)
+  const [correlatedLogs, setCorrelatedLogs] = useState<LogEvent[]>([])
+  const [historyCursor, setHistoryCursor] = useState<number | null>(null)
+  const [isLoadingHistory, setIsLoadingHistory] = useState(false)
+  const [bookmarks, setBookmarks] = useState<Bookmark[]>([])
+  const [pauseSummary, setPauseSummary] = useState<PauseSummary | null>(null)
+  const [sources, setSources] = useState<SourceStatus[]>([])
+  const [statuses, setStatuses] = useState<Record<string, StatusEvent>>({}) // latest shown, by source
 
   const wsRef = useRef<WebSocket | null>(null)
   const logsEndRef = useRef<HTMLDivElement>(null)
   const pausedLogsRef = useRef<LogEvent[]>([])
   const isPausedRef = useRef<boolean>(false)
+  const correlationRef = useRef<CorrelationFilter | null>(null)
+  const lastSeqRef = useRef<number | null>(null) // last event received, to resume from after reconnecting
+  const instanceRef = useRef<string | null>(null)
   const logsContainerRef = useRef<HTMLElement>(null)
+  const loadingHistoryRef = useRef<boolean>(false)
+  const prependScrollHeightRef = useRef<number | null>(null)
+  const skipAutoScrollRef = useRef<boolean>(false)
 
   // Apply dark mode
   useEffect(() => {
@@ -53,6 +74,10 @@ export default function LogViewer({}: LogViewerProps) {
     localStorage.setItem('logViewer.showTimestamps', showTimestamps.toString())
   }, [showTimestamps])
 
+  useEffect(() => {
+    localStorage.setItem('logViewer.showSourceTime', showSourceTime.toString())
+  }, [showSourceTime])
+
   useEffect(() => {
     localStorage.setItem('logViewer.stackTraceViewEnabled', stackTraceViewEnabled.toString())
   }, [stackTraceViewEnabled])
@@ -73,71 +98,268 @@ export default function LogViewer({}: LogViewerProps) {
     isPausedRef.current = isPaused
   }, [isPaused])
 
+  // Load every stored event with the selected trace or request ID
   useEffect(() => {
-    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
-    const wsUrl = `${protocol}//${window.location.host}/ws`
-    const ws = new WebSocket(wsUrl)
+    correlationRef.current = correlation
+    setCorrelatedLogs([])
+    if (!correlation) return
+
+    let cancelled = false
+    fetchCorrelated(correlation.id)
+      .then((res) => {
+        if (!cancelled) setCorrelatedLogs(res.events)
+      })
+      .catch((error) => console.error('Failed to load correlated events:', error))
 
-    ws.onopen = () => {
-      setConnected(true)
-      setIsLoading(false)
+    return () => {
+      cancelled = true
     }
+  }, [correlation])
 
-    ws.onmessage = (event) => {
-      const message: WebSocketMessage = JSON.parse(event.data)
+  useEffect(() => {
+    let ws: WebSocket | null = null
+    let retryTimer: ReturnType<typeof setTimeout> | undefined
+    let retryDelay = 1000
+    let closed = false
+
+    // Adds live or replayed events, skipping any already shown
+    const appendEvents = (events: LogEvent[]) => {
+      const fresh = events.filter((ev) => lastSeqRef.current === null || ev.seq > lastSeqRef.current)
+      if (fresh.length === 0) return
+      lastSeqRef.current = fresh[fresh.length - 1].seq
+
+      const filter = correlationRef.current
+      if (filter) {
+        const correlated = fresh.filter((ev) => Object.values(ev.correlation ?? {}).includes(filter.id))
+        if (correlated.length > 0) setCorrelatedLogs((prev) => [...prev, ...correlated])
+      }
 
-      if (message.type === 'snapshot' && message.events) {
-        setLogs(message.events)
-        if (message.sourceName) {
-          setSourceName(message.sourceName)
-          // Update document title with source name
-          const name = message.sourceName.split('/').pop() || message.sourceName
-          document.title = `${name} - shepai`
-        }
-      } else if (message.type === 'event' && message.event) {
-        if (isPausedRef.current) {
-          pausedLogsRef.current.push(message.event)
-        } else {
-          setLogs((prev) => [...prev, message.event!])
-        }
+      if (isPausedRef.current) {
+        pausedLogsRef.current.push(...fresh)
+      } else {
+        setLogs((prev) => [...prev, ...fresh])
       }
     }
 
-    ws.onerror = (error) => {
-      console.error('WebSocket error:', error)
-      setConnected(false)
-      setIsLoading(false)
+    const connect = () => {
+      const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
+      // After a disconnect, ask for only the events missed since the last one seen
+      const resume = lastSeqRef.current !== null && instanceRef.current
+        ? `&resume=${lastSeqRef.current}&instance=${encodeURIComponent(instanceRef.current)}`
+        : ''
+      ws = new WebSocket(`${protocol}//${window.location.host}/ws?session=${encodeURIComponent(session)}${resume}`)
+
+      ws.onopen = () => {
+        setConnected(true)
+        setIsLoading(false)
+        retryDelay = 1000
+        // A new connection isn't paused on the server
+        if (isPausedRef.current) sendMessage({ type: 'pause' })
+      }
+
+      ws.onmessage = (event) => {
+        const message: WebSocketMessage = JSON.parse(event.data)
+
+        if (message.type === 'snapshot' && message.events) {
+          setLogs(message.events)
+          pausedLogsRef.current = []
+          setHistoryCursor(message.first ? message.first : null)
+          instanceRef.current = message.instance ?? null
+          setBookmarks(message.bookmarks ?? [])
+          setStatuses(currentStatuses(message.statuses))
+          const last = message.events[message.events.length - 1]
+          lastSeqRef.current = last ? last.seq : null
+          if (message.sourceName) {
+            setSourceName(message.sourceName)
+            // Update document title with source name
+            const name = message.sourceName.split('/').pop() || message.sourceName
+            document.title = `${name} - shepai`
+          }
+        } else if ((message.type === 'events' || message.type === 'replay') && message.events) {
+          appendEvents(message.events)
+        } else if (message.type === 'gap' && message.lost && message.to !== undefined) {
+          // Mark where events were lost, after a long disconnect or when the
+          // browser couldn't keep up
+          appendEvents([{
+            timestamp: new Date().toISOString(),
+            source: 'file',
+            stream: '',
+            level: 'warning',
+            message: message.unfiltered
+              ? `Up to ${message.lost} matching ${message.lost === 1 ? 'event was' : 'events were'} not received`
+              : `${message.lost} ${message.lost === 1 ? 'event was' : 'events were'} not received`,
+            seq: message.to - 1,
+            gap: true,
+          }])
+        } else if (message.type === 'resumed' && message.seq !== undefined) {
+          lastSeqRef.current = Math.max(lastSeqRef.current ?? -1, message.seq)
+          if (message.bookmarks) setBookmarks(message.bookmarks)
+          if (message.statuses) setStatuses(currentStatuses(message.statuses))
+        } else if (message.type === 'status' && message.status) {
+          const status = message.status
+          setStatuses((prev) => ({ ...prev, [status.source]: status }))
+        } else if (message.type === 'bookmarks' && message.bookmarks) {
+          setBookmarks(message.bookmarks)
+        } else if (message.type === 'summary' && message.count) {
+          // What the server held while paused, sent right before it
+          setPauseSummary({ count: message.count, dropped: message.dropped ?? 0, levels: message.levels ?? {} })
+        }
+      }
+
+      ws.onerror = (error) => {
+        console.error('WebSocket error:', error)
+      }
+
+      ws.onclose = () => {
+        setConnected(false)
+        setIsLoading(false)
+        if (closed) return
+
+        // Reconnect with backoff
+        retryTimer = setTimeout(connect, retryDelay)
+        retryDelay = Math.min(retryDelay * 2, 30000)
+      }
+
+      wsRef.current = ws
     }
 
-    ws.onclose = () => {
-      setConnected(false)
-      setIsLoading(false)
+    const sendMessage = (message: PauseMessage) => {
+      if (ws?.readyState === WebSocket.OPEN) ws.send(JSON.stringify(message))
     }
 
-    wsRef.current = ws
+    connect()
 
     return () => {
-      ws.close()
+      closed = true
+      clearTimeout(retryTimer)
+      ws?.close()
     }
   }, [])
 
+  // Poll the state of the sources, to show when one isn't being followed
   useEffect(() => {
+    if (!connected) return
+
+    const poll = () => {
+      fetchStatus()
+        .then((res) => setSources(res.sources))
+        .catch((error) => console.error('Failed to load status:', error))
+    }
+    poll()
+    const timer = setInterval(poll, 5000)
+    return () => clearInterval(timer)
+  }, [connected])
+
+  const dismissStatus = (status: StatusEvent) => {
+    setStatuses((prev) => {
+      if (prev[status.source] !== status) return prev
+      const next = { ...prev }
+      delete next[status.source]
+      return next
+    })
+  }
+
+  // Info statuses, such as a reconnect, hide after 5s; warnings and errors
+  // stay until the next status of their source or are dismissed
+  useEffect(() => {
+    const timers = Object.values(statuses)
+      .filter((status) => status.severity === 'info')
+      .map((status) => setTimeout(() => dismissStatus(status), 5000))
+    return () => timers.forEach(clearTimeout)
+  }, [statuses])
+
+  // Keep the rows in view in place when older events are prepended
+  useLayoutEffect(() => {
+    const container = logsContainerRef.current
+    if (prependScrollHeightRef.current === null || !container) return
+
+    container.scrollTop += container.scrollHeight - prependScrollHeightRef.current
+    prependScrollHeightRef.current = null
+    skipAutoScrollRef.current = true
+  }, [logs])
+
+  useEffect(() => {
+    if (skipAutoScrollRef.current) {
+      skipAutoScrollRef.current = false
+      return
+    }
     if (autoScroll && !isPaused && logsEndRef.current) {
       logsEndRef.current.scrollIntoView({ behavior: 'smooth' })
     }
   }, [logs, autoScroll, isPaused])
 
+  // Pausing holds live events on the server; unpausing has it send a
+  // summary of them, then the events
   const handlePause = () => {
-    if (isPaused) {
-      // Resume: add paused logs
+    const paused = !isPaused
+    const message: PauseMessage = { type: paused ? 'pause' : 'unpause' }
+    if (wsRef.current?.readyState === WebSocket.OPEN) wsRef.current.send(JSON.stringify(message))
+
+    if (paused) {
+      setPauseSummary(null)
+    } else {
+      // Events that arrived before the server paused
       setLogs((prev) => [...prev, ...pausedLogsRef.current])
       pausedLogsRef.current = []
     }
-    setIsPaused(!isPaused)
+    isPausedRef.current = paused
+    setIsPaused(paused)
+  }
+
+  const handleBookmark = (seq: number) => {
+    const note = window.prompt('Bookmark note, e.g. "deploy started here" (optional)')
+    if (note === null) return
+    addBookmark(seq, note).catch((error) => console.error('Failed to add bookmark:', error))
+  }
+
+  const handleRemoveBookmark = (id: string) => {
+    removeBookmark(id).catch((error) => console.error('Failed to remove bookmark:', error))
+  }
+
+  // Scrolls to a bookmarked event, if it's loaded
+  const scrollToSeq = (seq: number) => {
+    const row = logsContainerRef.current?.querySelector(`[data-seq="${seq}"]`)
+    row?.scrollIntoView({ behavior: 'smooth', block: 'center' })
+  }
+
+  const bookmarksBySeq = useMemo(() => {
+    const bySeq = new Map<number, Bookmark[]>()
+    for (const bookmark of bookmarks) {
+      bySeq.set(bookmark.seq, [...(bySeq.get(bookmark.seq) ?? []), bookmark])
+    }
+    return bySeq
+  }, [bookmarks])
+
+  // Prepend the page of events before the oldest one shown
+  const handleLoadOlder = async () => {
+    if (historyCursor === null || loadingHistoryRef.current) return
+
+    loadingHistoryRef.current = true
+    setIsLoadingHistory(true)
+    try {
+      const res = await fetchEvents({ before: historyCursor })
+      prependScrollHeightRef.current = logsContainerRef.current?.scrollHeight ?? null
+      setLogs((prev) => [...res.events, ...prev])
+      setHistoryCursor(res.nextBefore ?? null)
+    } catch (error) {
+      console.error('Failed to load older events:', error)
+    } finally {
+      loadingHistoryRef.current = false
+      setIsLoadingHistory(false)
+    }
+  }
+
+  // Load older events when scrolled to the top
+  const handleLogsScroll = () => {
+    const container = logsContainerRef.current
+    if (container && container.scrollTop < 100 && !correlation) {
+      handleLoadOlder()
+    }
   }
 
   const handleClearAll = () => {
     setLogs([])
+    setHistoryCursor(null)
     pausedLogsRef.current = []
     setSearchQuery('')
     setExpanded({})
@@ -165,7 +387,10 @@ export default function LogViewer({}: LogViewerProps) {
     setZoomLevel((prev) => Math.max(prev - 0.1, 0.5)) // Min 50%
   }
 
-  const displayLogs = useMemo(() => groupLogEventsForDisplay(logs, stackTraceViewEnabled), [logs, stackTraceViewEnabled])
+  const displayLogs = useMemo(
+    () => groupLogEventsForDisplay(correlation ? correlatedLogs : logs, stackTraceViewEnabled),
+    [logs, correlatedLogs, correlation, stackTraceViewEnabled],
+  )
 
   // First apply search filter only (for counting badges)
   const searchFilteredLogs = useMemo(() => displayLogs.filter((log) => {
@@ -178,7 +403,7 @@ export default function LogViewer({}: LogViewerProps) {
   // Then apply both search and level filters (for display)
   const filteredLogs = useMemo(() => searchFilteredLogs.filter((log) => {
     if (selectedLevel) {
-      const level = getSeverityLevel(log.header)
+      const level = resolveSeverityLevel(log.level, log.header)
       if (level !== selectedLevel) return false
     }
     return true
@@ -196,7 +421,7 @@ export default function LogViewer({}: LogViewerProps) {
 
     // Count from search-filtered logs to reflect search results
     for (const log of searchFilteredLogs) {
-      const level = getSeverityLevel(log.header)
+      const level = resolveSeverityLevel(log.level, log.header)
       counts[level]++
     }
 
@@ -212,6 +437,8 @@ export default function LogViewer({}: LogViewerProps) {
         sourceName={sourceName}
         showTimestamps={showTimestamps}
         onToggleTimestamps={() => setShowTimestamps(!showTimestamps)}
+        showSourceTime={showSourceTime}
+        onToggleSourceTime={() => setShowSourceTime(!showSourceTime)}
         stackTraceViewEnabled={stackTraceViewEnabled}
         onToggleStackTraceView={() => setStackTraceViewEnabled(!stackTraceViewEnabled)}
         autoScroll={autoScroll}
@@ -257,9 +484,130 @@ export default function LogViewer({}: LogViewerProps) {
         </div>
       )}
 
+      {/* Correlation Filter */}
+      {correlation && (
+        <div className="bg-primary/5 border-b border-primary/20 px-4 py-2">
+          <p className="text-center text-sm flex items-center justify-center gap-2">
+            <Link2 className="w-4 h-4 text-muted-foreground" />
+            Showing events with {correlation.kind} ID
+            <span className="font-mono text-xs">{correlation.id}</span>
+            <button
+              type="button"
+              onClick={() => setCorrelation(null)}
+              className="text-muted-foreground hover:text-foreground hover:scale-110 active:scale-95 transition-all duration-150"
+              title="Show all events"
+            >
+              <X className="w-4 h-4" />
+            </button>
+          </p>
+        </div>
+      )}
+
+      {/* Status events of the sources */}
+      {Object.values(statuses).map((status) => (
+        <div
+          key={status.source}
+          className={status.severity === 'error'
+            ? 'bg-destructive/5 border-b border-destructive/20 px-4 py-2'
+            : status.severity === 'warning'
+              ? 'bg-amber-500/5 border-b border-amber-500/20 px-4 py-2'
+              : 'bg-blue-500/5 border-b border-blue-500/20 px-4 py-2'}
+        >
+          <p
+            className={`text-center text-sm flex items-center justify-center gap-2 ${status.severity === 'error'
+              ? 'text-destructive'
+              : status.severity === 'warning'
+                ? 'text-amber-700 dark:text-amber-300'
+                : 'text-blue-600 dark:text-blue-400'}`}
+          >
+            {status.severity === 'error' ? <XCircle className="w-4 h-4" /> : status.severity === 'warning' ? <AlertTriangle className="w-4 h-4" /> : <Info className="w-4 h-4" />}
+            {status.message}
+            <span className="text-muted-foreground text-xs">{new Date(status.time).toLocaleTimeString()}</span>
+            <button
+              type="button"
+              onClick={() => dismissStatus(status)}
+              className="text-muted-foreground hover:text-foreground hover:scale-110 active:scale-95 transition-all duration-150"
+              title="Dismiss"
+            >
+              <X className="w-4 h-4" />
+            </button>
+          </p>
+        </div>
+      ))}
+
+      {/* Sources that aren't being followed, unless a status event explains it */}
+      {connected && sources.filter((source) => source.state !== 'following' && source.state !== 'unknown' && !statuses[source.name]).map((source) => (
+        <div key={source.name} className="bg-amber-500/5 border-b border-amber-500/20 px-4 py-2">
+          <p className="text-center text-sm text-amber-700 dark:text-amber-300 flex items-center justify-center gap-2">
+            <AlertTriangle className="w-4 h-4" />
+            <span className="font-mono text-xs">{source.name}</span>
+            {source.state}
+            {source.detail && <span className="text-muted-foreground">({source.detail})</span>}
+            {source.attempt ? <span className="text-muted-foreground">attempt {source.attempt}</span> : null}
+          </p>
+        </div>
+      ))}
+
+      {/* Summary of the events held while paused */}
+      {pauseSummary && (
+        <div className="bg-amber-500/5 border-b border-amber-500/20 px-4 py-2">
+          <p className="text-center text-sm flex items-center justify-center gap-2">
+            <Pause className="w-4 h-4 text-muted-foreground" />
+            {pauseSummary.count} {pauseSummary.count === 1 ? 'event' : 'events'} arrived while paused
+            {Object.keys(pauseSummary.levels).length > 0 && (
+              <span className="text-muted-foreground">
+                ({Object.entries(pauseSummary.levels).map(([level, count]) => `${count} ${level}`).join(', ')})
+              </span>
+            )}
+            {pauseSummary.dropped > 0 && (
+              <span className="text-destructive">{pauseSummary.dropped} not received</span>
+            )}
+            <button
+              type="button"
+              onClick={() => setPauseSummary(null)}
+              className="text-muted-foreground hover:text-foreground hover:scale-110 active:scale-95 transition-all duration-150"
+              title="Dismiss"
+            >
+              <X className="w-4 h-4" />
+            </button>
+          </p>
+        </div>
+      )}
+
+      {/* Bookmarks shared by everyone in this session */}
+      {bookmarks.length > 0 && (
+        <div className="border-b border-border/40 px-4 py-1.5 flex flex-wrap items-center gap-1.5">
+          <BookmarkIcon className="w-4 h-4 text-amber-500" />
+          {bookmarks.map((bookmark) => (
+            <button
+              key={bookmark.id}
+              type="button"
+              onClick={() => scrollToSeq(bookmark.seq)}
+              className="inline-flex items-center gap-1 rounded border border-amber-500/40 bg-amber-500/10 hover:bg-amber-500/20 transition-all duration-150 active:scale-95 px-1.5 py-0.5 text-[11px]"
+              title={`Event #${bookmark.seq}${bookmark.author ? `, by ${bookmark.author}` : ''}`}
+            >
+              {bookmark.note || `#${bookmark.seq}`}
+            </button>
+          ))}
+        </div>
+      )}
+
       {/* Logs Container */}
-      <main ref={logsContainerRef} className="flex-1 overflow-auto">
+      <main ref={logsContainerRef} onScroll={handleLogsScroll} className="flex-1 overflow-auto">
         <div className="container mx-auto px-2 sm:px-6 lg:px-8 py-2 sm:py-4">
+          {historyCursor !== null && !correlation && (
+            <div className="flex justify-center mb-2">
+              <button
+                type="button"
+                onClick={handleLoadOlder}
+                disabled={isLoadingHistory}
+                className="text-sm text-muted-foreground hover:text-foreground flex items-center gap-2 px-3 py-1 rounded-md border border-border/40 bg-card/60 transition-all duration-150 disabled:opacity-50"
+              >
+                <History className="w-4 h-4" />
+                {isLoadingHistory ? 'Loading…' : 'Load older events'}
+              </button>
+            </div>
+          )}
           {filteredLogs.length === 0 ? (
             <div className="flex flex-col items-center justify-center h-full min-h-[400px] text-center">
               <div className="w-16 h-16 rounded-full bg-muted flex items-center justify-center mb-4">
@@ -282,7 +630,12 @@ export default function LogViewer({}: LogViewerProps) {
                   onToggleJsonViewer={(key) => setJsonViewerEnabled((prev) => ({ ...prev, [key]: !(prev[key] ?? jsonViewerGlobalEnabled) }))}
                   focusedLogKey={focusedLogKey}
                   onToggleFocus={(key) => setFocusedLogKey((prev) => (prev !== null ? null : key))}
+                  onCorrelate={(kind, id) => setCorrelation({ kind, id })}
+                  bookmarksBySeq={bookmarksBySeq}
+                  onBookmark={handleBookmark}
+                  onRemoveBookmark={handleRemoveBookmark}
                   showTimestamps={showTimestamps}
+                  showSourceTime={showSourceTime}
                   searchQuery={searchQuery}
                   isDarkMode={isDarkMode}
                   ansiConverter={ansiConverter}
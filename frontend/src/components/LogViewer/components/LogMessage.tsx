@@ -1,11 +1,14 @@
 import type Convert from 'ansi-to-html'
 import JsonView from '@uiw/react-json-view'
+import type { StyleSpan } from '../../../types/log'
 import type { LogLevel } from '../enums'
+import { styledTextToHtml } from '../utils/ansi'
 import { tryParseJSON } from '../utils/json'
 import { getSeverityKeyColor } from '../utils/severity'
 
 interface LogMessageProps {
   text: string
+  styles?: StyleSpan[]
   query: string
   severity?: LogLevel
   showJsonViewer?: boolean
@@ -13,7 +16,7 @@ interface LogMessageProps {
   isDarkMode: boolean
 }
 
-export const LogMessage = ({ text, query, severity, showJsonViewer, ansiConverter, isDarkMode }: LogMessageProps) => {
+export const LogMessage = ({ text, styles, query, severity, showJsonViewer, ansiConverter, isDarkMode }: LogMessageProps) => {
   // Try to parse as JSON first
   const jsonData = tryParseJSON(text)
 
@@ -93,8 +96,8 @@ export const LogMessage = ({ text, query, severity, showJsonViewer, ansiConverte
     )
   }
 
-  // Convert ANSI codes to HTML
-  const html = ansiConverter.toHtml(text)
+  // Render the server's style spans, or convert any raw ANSI codes to HTML
+  const html = styles && styles.length > 0 ? styledTextToHtml(text, styles, isDarkMode) : ansiConverter.toHtml(text)
 
   // If there's a search query, we need to highlight matches
   // But we'll do it after ANSI conversion to preserve colors
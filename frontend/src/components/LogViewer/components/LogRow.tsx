@@ -1,16 +1,18 @@
-import { ChevronDown, ChevronRight } from 'lucide-react'
+import { Bookmark as BookmarkIcon, ChevronDown, ChevronRight, Link2, ShieldCheck, X } from 'lucide-react'
 import type Convert from 'ansi-to-html'
+import type { Bookmark } from '../../../types/log'
 import type { DisplayLogEvent } from '../types'
 import type { LogLevel } from '../enums'
-import { formatTimestamp } from '../utils/time'
+import { formatSourceTime, formatSourceTimestamp, formatTimestamp } from '../utils/time'
 import { tryParseJSON } from '../utils/json'
-import { getSeverityColor, getSeverityLevel } from '../utils/severity'
+import { getSeverityLevelColor, resolveSeverityLevel } from '../utils/severity'
 import { LogMessage } from './LogMessage'
 
 interface LogRowProps {
   log: DisplayLogEvent
   index: number
   showTimestamps: boolean
+  showSourceTime: boolean
   searchQuery: string
   isDarkMode: boolean
   ansiConverter: Convert
@@ -24,12 +26,19 @@ interface LogRowProps {
 
   focusedLogKey: string | null
   onToggleFocus: () => void
+
+  onCorrelate: (kind: string, id: string) => void
+
+  bookmarks?: Bookmark[]
+  onBookmark?: () => void // unset for rows that aren't an event's first
+  onRemoveBookmark: (id: string) => void
 }
 
 export const LogRow = ({
   log,
   index,
   showTimestamps,
+  showSourceTime,
   searchQuery,
   isDarkMode,
   ansiConverter,
@@ -40,6 +49,10 @@ export const LogRow = ({
   onToggleJsonViewer,
   focusedLogKey,
   onToggleFocus,
+  onCorrelate,
+  bookmarks,
+  onBookmark,
+  onRemoveBookmark,
 }: LogRowProps) => {
   const isExpanded = expanded
   const hasDetails = log.details.length > 0
@@ -47,7 +60,7 @@ export const LogRow = ({
   
   const textToDisplay = log.header
 
-  const severity: LogLevel = getSeverityLevel(log.header)
+  const severity: LogLevel = resolveSeverityLevel(log.level, log.header)
   const hasJson = !!tryParseJSON(log.header)
   const showJsonViewer = jsonViewerEnabled ?? jsonViewerGlobalEnabled
 
@@ -57,25 +70,26 @@ export const LogRow = ({
 
   return (
     <div
+      data-seq={log.seq}
       onClick={() => {
         // Don't toggle focus if user is selecting text
         if (window.getSelection()?.toString()) return
         onToggleFocus()
       }}
       className={`
-        transition-all duration-300 ease-in-out cursor-pointer
+        group transition-all duration-300 ease-in-out cursor-pointer
         ${index % 2 === 0 ? 'bg-muted/60 dark:bg-muted/60' : 'bg-transparent'}
         ${isBlurred ? 'opacity-30 blur-[1px] grayscale-[0.5]' : ''}
         ${isFocused ? 'ring-1 ring-primary/40 shadow-lg scale-[1.01] z-10 rounded-sm !bg-background dark:!bg-background my-1 border-y border-border/50 relative' : 'hover:bg-blue-50/80 dark:hover:bg-blue-950/40 hover:shadow-sm'}
       `}
     >
-      <div className={`flex gap-2 sm:gap-4 py-2 sm:py-3 px-2 sm:px-4 ${getSeverityColor(log.header)}`}>
+      <div className={`flex gap-2 sm:gap-4 py-2 sm:py-3 px-2 sm:px-4 ${getSeverityLevelColor(severity)}`}>
         {showTimestamps && (
           <span
             className="text-gray-500 dark:text-gray-400 flex-shrink-0 pt-0.5 font-medium tracking-wide hidden sm:block"
             style={{ fontSize: '10px' }}
           >
-            {formatTimestamp(log.timestamp)}
+            {showSourceTime && log.zone ? formatSourceTimestamp(log.timestamp, log.zone) : formatTimestamp(log.timestamp)}
           </span>
         )}
         {showTimestamps && (
@@ -83,7 +97,7 @@ export const LogRow = ({
             className="text-gray-500 dark:text-gray-400 flex-shrink-0 pt-0.5 font-medium tracking-wide sm:hidden"
             style={{ fontSize: '10px' }}
           >
-            {new Date(log.timestamp).toLocaleTimeString()}
+            {showSourceTime && log.zone ? formatSourceTime(log.timestamp) : new Date(log.timestamp).toLocaleTimeString()}
           </span>
         )}
 
@@ -124,6 +138,7 @@ export const LogRow = ({
             <span className="flex-1 break-words font-mono text-[11px] leading-relaxed">
               <LogMessage
                 text={textToDisplay}
+                styles={log.styles}
                 query={searchQuery}
                 severity={severity}
                 showJsonViewer={showJsonViewer}
@@ -131,13 +146,83 @@ export const LogRow = ({
                 isDarkMode={isDarkMode}
               />
             </span>
+
+            {log.redactions && log.redactions.length > 0 && (
+              <span
+                className="mt-0.5 flex-shrink-0 text-muted-foreground"
+                title={`Redacted: ${log.redactions.join(', ')}`}
+              >
+                <ShieldCheck className="w-3 h-3" />
+              </span>
+            )}
+
+            {onBookmark && (
+              <button
+                type="button"
+                onClick={(e) => {
+                  e.stopPropagation()
+                  onBookmark()
+                }}
+                className={`mt-0.5 flex-shrink-0 text-muted-foreground hover:text-foreground hover:scale-110 active:scale-95 transition-all duration-150 ${bookmarks && bookmarks.length > 0 ? 'text-amber-500' : 'opacity-0 group-hover:opacity-100'}`}
+                title="Bookmark this event for everyone watching"
+              >
+                <BookmarkIcon className="w-3 h-3" />
+              </button>
+            )}
           </div>
 
+          {bookmarks && bookmarks.length > 0 && (
+            <div className="mt-1.5 ml-8 flex flex-wrap gap-1.5">
+              {bookmarks.map((bookmark) => (
+                <span
+                  key={bookmark.id}
+                  className="inline-flex items-center gap-1 rounded border border-amber-500/40 bg-amber-500/10 px-1.5 py-0.5 text-[10px] text-amber-700 dark:text-amber-300"
+                  title={`Bookmarked ${new Date(bookmark.createdAt).toLocaleString()}${bookmark.author ? ` by ${bookmark.author}` : ''}`}
+                >
+                  <BookmarkIcon className="w-3 h-3" />
+                  {bookmark.note || 'Bookmark'}
+                  <button
+                    type="button"
+                    onClick={(e) => {
+                      e.stopPropagation()
+                      onRemoveBookmark(bookmark.id)
+                    }}
+                    className="hover:text-foreground hover:scale-110 active:scale-95 transition-all duration-150"
+                    title="Remove bookmark"
+                  >
+                    <X className="w-3 h-3" />
+                  </button>
+                </span>
+              ))}
+            </div>
+          )}
+
+          {log.correlation && (
+            <div className="mt-1.5 ml-8 flex flex-wrap gap-1.5">
+              {Object.entries(log.correlation).map(([kind, id]) => (
+                <button
+                  key={kind}
+                  type="button"
+                  onClick={(e) => {
+                    e.stopPropagation()
+                    onCorrelate(kind, id)
+                  }}
+                  className="inline-flex items-center gap-1 rounded border border-border/50 bg-background/60 hover:bg-accent hover:border-border transition-all duration-150 active:scale-95 px-1.5 py-0.5 font-mono text-[10px] text-muted-foreground"
+                  title={`Show all events with this ${kind} ID`}
+                >
+                  <Link2 className="w-3 h-3" />
+                  {kind}: {id.length > 16 ? `${id.slice(0, 16)}…` : id}
+                </button>
+              ))}
+            </div>
+          )}
+
           {hasDetails && isExpanded && (
             <div className="mt-3 rounded-md border border-border/40 bg-muted/30 dark:bg-muted/20 shadow-inner p-3">
               <pre className="font-mono text-[10px] leading-relaxed whitespace-pre-wrap break-words text-muted-foreground">
                 <LogMessage
                   text={log.details.join('\n')}
+                  styles={log.detailStyles}
                   query={searchQuery}
                   severity={severity}
                   showJsonViewer={showJsonViewer}
@@ -1,4 +1,4 @@
-import { ArrowDown, ArrowUp, Braces, Eye, EyeOff, Layers, Minus, Moon, Pause, Play, Plus, Search, Sun, Trash2, X } from 'lucide-react'
+import { ArrowDown, ArrowUp, Braces, Clock, Eye, EyeOff, Layers, Minus, Moon, Pause, Play, Plus, Search, Sun, Trash2, X } from 'lucide-react'
 import { Button } from '../../ui/button'
 import { Input } from '../../ui/input'
 
@@ -7,6 +7,9 @@ interface LogViewerHeaderProps {
   showTimestamps: boolean
   onToggleTimestamps: () => void
 
+  showSourceTime: boolean
+  onToggleSourceTime: () => void
+
   stackTraceViewEnabled: boolean
   onToggleStackTraceView: () => void
 
@@ -41,6 +44,8 @@ export const LogViewerHeader = ({
   sourceName,
   showTimestamps,
   onToggleTimestamps,
+  showSourceTime,
+  onToggleSourceTime,
   stackTraceViewEnabled,
   onToggleStackTraceView,
   autoScroll,
@@ -100,6 +105,19 @@ export const LogViewerHeader = ({
               )}
             </Button>
 
+            {showTimestamps && (
+              <Button
+                variant="outline"
+                size="sm"
+                onClick={onToggleSourceTime}
+                className="h-7 px-2.5 text-[11px] whitespace-nowrap"
+                title={showSourceTime ? 'Show times in your local timezone' : 'Show times as written by the source'}
+              >
+                <Clock className="w-3 h-3" />
+                <span className="ml-1.5 hidden sm:inline">Time: {showSourceTime ? 'Source' : 'Local'}</span>
+              </Button>
+            )}
+
             <Button
               variant="outline"
               size="sm"
@@ -1,4 +1,5 @@
 import type Convert from 'ansi-to-html'
+import type { Bookmark } from '../../../types/log'
 import type { DisplayLogEvent } from '../types'
 import { LogRow } from './LogRow'
 
@@ -14,7 +15,14 @@ interface LogViewerListProps {
   focusedLogKey: string | null
   onToggleFocus: (key: string) => void
 
+  onCorrelate: (kind: string, id: string) => void
+
+  bookmarksBySeq: Map<number, Bookmark[]>
+  onBookmark: (seq: number) => void
+  onRemoveBookmark: (id: string) => void
+
   showTimestamps: boolean
+  showSourceTime: boolean
   searchQuery: string
   isDarkMode: boolean
   ansiConverter: Convert
@@ -29,7 +37,12 @@ export const LogViewerList = ({
   onToggleJsonViewer,
   focusedLogKey,
   onToggleFocus,
+  onCorrelate,
+  bookmarksBySeq,
+  onBookmark,
+  onRemoveBookmark,
   showTimestamps,
+  showSourceTime,
   searchQuery,
   isDarkMode,
   ansiConverter,
@@ -42,6 +55,7 @@ export const LogViewerList = ({
           log={log}
           index={index}
           showTimestamps={showTimestamps}
+          showSourceTime={showSourceTime}
           searchQuery={searchQuery}
           isDarkMode={isDarkMode}
           ansiConverter={ansiConverter}
@@ -52,6 +66,10 @@ export const LogViewerList = ({
           onToggleJsonViewer={() => onToggleJsonViewer(log.key)}
           focusedLogKey={focusedLogKey}
           onToggleFocus={() => onToggleFocus(log.key)}
+          onCorrelate={onCorrelate}
+          bookmarks={log.seq !== undefined ? bookmarksBySeq.get(log.seq) : undefined}
+          onBookmark={log.seq !== undefined ? () => onBookmark(log.seq!) : undefined}
+          onRemoveBookmark={onRemoveBookmark}
         />
       ))}
     </div>
@@ -3,13 +3,31 @@ import type { LogLevel } from './enums'
 
 export type DisplayLogEvent = {
   key: string
+  seq?: number // sequence ID of the event, on its first row only
   timestamp: string
+  zone?: LogEvent['zone']
   source: LogEvent['source']
   stream: LogEvent['stream']
+  level?: LogEvent['level'] // level extracted by a server-side parser rule
   header: string
+  styles?: LogEvent['styles'] // ANSI styling of header
   details: string[] // continuation lines (e.g. stack frames)
+  detailStyles?: LogEvent['detailStyles'] // ANSI styling of details joined by newlines
+  redactions?: LogEvent['redactions']
+  correlation?: LogEvent['correlation']
 }
 
 export type LogLevelCounts = Record<LogLevel, number>
 
+// Live events the server held while the view was paused
+export type PauseSummary = {
+  count: number
+  dropped: number
+  levels: Record<string, number>
+}
 
+// A trace or request ID the view is filtered to
+export type CorrelationFilter = {
+  kind: string
+  id: string
+}
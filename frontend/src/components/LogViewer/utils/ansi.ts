@@ -1,4 +1,5 @@
 import Convert from 'ansi-to-html'
+import type { StyleSpan } from '../../../types/log'
 
 export const createAnsiConverter = (isDarkMode: boolean) => {
   return new Convert({
@@ -10,4 +11,58 @@ export const createAnsiConverter = (isDarkMode: boolean) => {
   })
 }
 
+const ansiPalette = (isDarkMode: boolean): Record<string, string> => ({
+  black: isDarkMode ? '#4B5563' : '#000000',
+  red: isDarkMode ? '#F87171' : '#B91C1C',
+  green: isDarkMode ? '#4ADE80' : '#15803D',
+  yellow: isDarkMode ? '#FACC15' : '#A16207',
+  blue: isDarkMode ? '#60A5FA' : '#1D4ED8',
+  magenta: isDarkMode ? '#E879F9' : '#A21CAF',
+  cyan: isDarkMode ? '#22D3EE' : '#0E7490',
+  white: isDarkMode ? '#E5E7EB' : '#6B7280',
+  brightBlack: isDarkMode ? '#9CA3AF' : '#4B5563',
+  brightRed: isDarkMode ? '#FCA5A5' : '#DC2626',
+  brightGreen: isDarkMode ? '#86EFAC' : '#16A34A',
+  brightYellow: isDarkMode ? '#FDE047' : '#CA8A04',
+  brightBlue: isDarkMode ? '#93C5FD' : '#2563EB',
+  brightMagenta: isDarkMode ? '#F0ABFC' : '#C026D3',
+  brightCyan: isDarkMode ? '#67E8F9' : '#0891B2',
+  brightWhite: isDarkMode ? '#F9FAFB' : '#374151',
+})
 
+const escapeHtml = (text: string): string =>
+  text.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;').replace(/"/g, '&quot;')
+
+// Renders text with the ANSI style spans sent by the server as HTML
+export const styledTextToHtml = (text: string, styles: StyleSpan[], isDarkMode: boolean): string => {
+  const palette = ansiPalette(isDarkMode)
+  const color = (c?: string) => (c ? palette[c] ?? c : undefined)
+
+  let html = ''
+  let pos = 0
+  for (const span of styles) {
+    if (span.start > pos) {
+      html += escapeHtml(text.slice(pos, span.start))
+    }
+
+    let fg = color(span.fg)
+    let bg = color(span.bg)
+    if (span.inverse) {
+      ;[fg, bg] = [bg ?? (isDarkMode ? '#0F172A' : '#FFFFFF'), fg ?? (isDarkMode ? '#E5E7EB' : '#1F2937')]
+    }
+
+    const css = [
+      fg && `color:${fg}`,
+      bg && `background-color:${bg}`,
+      span.bold && 'font-weight:bold',
+      span.dim && 'opacity:0.7',
+      span.italic && 'font-style:italic',
+      span.underline && 'text-decoration:underline',
+    ].filter(Boolean).join(';')
+
+    html += `<span style="${css}">${escapeHtml(text.slice(span.start, span.end))}</span>`
+    pos = span.end
+  }
+
+  return html + escapeHtml(text.slice(pos))
+}
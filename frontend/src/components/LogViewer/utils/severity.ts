@@ -1,44 +1,70 @@
 import type { LogLevel } from '../enums'
 import { LogLevel as LogLevelEnum } from '../enums'
 
+// Words that suggest a level, tried in order. Words match whole, or with any
+// ending ("errors", "warning") or beginning ("TypeError") where allowed, so
+// "ok" doesn't match "token". Mirrors query.Level on the server.
+const levelGuesses: { level: LogLevel; words?: string[]; prefixes?: string[]; suffixes?: string[] }[] = [
+  { level: LogLevelEnum.ERROR, prefixes: ['error', 'fatal', 'exception'], suffixes: ['error', 'exception'] },
+  { level: LogLevelEnum.WARNING, prefixes: ['warn'] },
+  { level: LogLevelEnum.INFO, prefixes: ['info'] },
+  { level: LogLevelEnum.DEBUG, prefixes: ['debug'] },
+  { level: LogLevelEnum.SUCCESS, words: ['ok'], prefixes: ['success'] },
+]
+
 export const getSeverityLevel = (message: string): LogLevel => {
-  const lower = message.toLowerCase()
-  if (lower.includes('error') || lower.includes('fatal') || lower.includes('exception')) {
-    return LogLevelEnum.ERROR
-  }
-  if (lower.includes('warning') || lower.includes('warn')) {
-    return LogLevelEnum.WARNING
-  }
-  if (lower.includes('info') || lower.includes('information')) {
-    return LogLevelEnum.INFO
-  }
-  if (lower.includes('debug')) {
-    return LogLevelEnum.DEBUG
-  }
-  if (lower.includes('success') || lower.includes('ok')) {
-    return LogLevelEnum.SUCCESS
+  const words = message.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(Boolean)
+  for (const guess of levelGuesses) {
+    const matches = words.some(
+      (word) =>
+        (guess.words ?? []).includes(word) ||
+        (guess.prefixes ?? []).some((prefix) => word.startsWith(prefix)) ||
+        (guess.suffixes ?? []).some((suffix) => word.endsWith(suffix)),
+    )
+    if (matches) {
+      return guess.level
+    }
   }
   return LogLevelEnum.DEFAULT
 }
 
-export const getSeverityColor = (message: string): string => {
-  const lower = message.toLowerCase()
-  if (lower.includes('error') || lower.includes('fatal') || lower.includes('exception')) {
-    return 'text-red-600 dark:text-red-400 font-medium'
-  }
-  if (lower.includes('warning') || lower.includes('warn')) {
-    return 'text-amber-600 dark:text-amber-400'
-  }
-  if (lower.includes('info') || lower.includes('information')) {
-    return 'text-blue-600 dark:text-blue-400'
-  }
-  if (lower.includes('debug')) {
-    return 'text-gray-500 dark:text-gray-400'
+export const getSeverityLevelColor = (level: LogLevel): string => {
+  const colorMap: Record<LogLevel, string> = {
+    [LogLevelEnum.ERROR]: 'text-red-600 dark:text-red-400 font-medium',
+    [LogLevelEnum.WARNING]: 'text-amber-600 dark:text-amber-400',
+    [LogLevelEnum.INFO]: 'text-blue-600 dark:text-blue-400',
+    [LogLevelEnum.DEBUG]: 'text-gray-500 dark:text-gray-400',
+    [LogLevelEnum.SUCCESS]: 'text-green-600 dark:text-green-400',
+    [LogLevelEnum.DEFAULT]: 'text-foreground',
   }
-  if (lower.includes('success') || lower.includes('ok')) {
-    return 'text-green-600 dark:text-green-400'
+
+  return colorMap[level]
+}
+
+// Maps a level extracted by a parser rule (e.g. "err", "warning", "notice") onto a LogLevel.
+// Falls back to guessing from the message text when the level is missing or unknown.
+export const resolveSeverityLevel = (level: string | undefined, message: string): LogLevel => {
+  switch ((level ?? '').toLowerCase()) {
+    case 'emergency':
+    case 'alert':
+    case 'critical':
+    case 'crit':
+    case 'fatal':
+    case 'error':
+    case 'err':
+      return LogLevelEnum.ERROR
+    case 'warning':
+    case 'warn':
+      return LogLevelEnum.WARNING
+    case 'notice':
+    case 'info':
+      return LogLevelEnum.INFO
+    case 'debug':
+    case 'trace':
+      return LogLevelEnum.DEBUG
+    default:
+      return getSeverityLevel(message)
   }
-  return 'text-foreground'
 }
 
 export const getSeverityKeyColor = (severity: LogLevel | undefined, isDarkMode: boolean): string => {
@@ -7,4 +7,17 @@ export const formatTimestamp = (timestamp: string): string => {
   }
 }
 
+// Formats the wall-clock time as written by the source, using the offset the
+// server encoded into the timestamp (e.g. 2025-01-01T12:00:00+09:00)
+export const formatSourceTimestamp = (timestamp: string, zone?: string): string => {
+  const match = /^(\d{4}-\d{2}-\d{2})T(\d{2}:\d{2}:\d{2})/.exec(timestamp)
+  if (!match) {
+    return formatTimestamp(timestamp)
+  }
+  return zone ? `${match[1]} ${match[2]} ${zone}` : `${match[1]} ${match[2]}`
+}
 
+export const formatSourceTime = (timestamp: string): string => {
+  const match = /T(\d{2}:\d{2}:\d{2})/.exec(timestamp)
+  return match ? match[1] : new Date(timestamp).toLocaleTimeString()
+}
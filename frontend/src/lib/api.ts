@@ -0,0 +1,57 @@
+import type { Bookmark, CorrelateResponse, HistoryResponse, StatusResponse } from '../types/log'
+
+// Fetches every stored event carrying a trace or request ID
+export async function fetchCorrelated(id: string): Promise<CorrelateResponse> {
+  const res = await fetch(`/api/correlate?id=${encodeURIComponent(id)}`)
+  if (!res.ok) {
+    throw new Error(`correlate request failed: ${res.status}`)
+  }
+  return res.json()
+}
+
+// Fetches a page of events before or after a sequence ID
+export async function fetchEvents(cursor: { before: number } | { after: number }, limit = 200): Promise<HistoryResponse> {
+  const param = 'before' in cursor ? `before=${cursor.before}` : `after=${cursor.after}`
+  const res = await fetch(`/api/events?${param}&limit=${limit}`)
+  if (!res.ok) {
+    throw new Error(`events request failed: ${res.status}`)
+  }
+  return res.json()
+}
+
+// The bookmark session of this page, from ?session= in its URL; pages
+// opened with the same one share bookmarks, the rest share the default
+export const session = new URLSearchParams(window.location.search).get('session') ?? ''
+
+// Bookmarks an event; every client of the session receives the new list
+// over /ws
+export async function addBookmark(seq: number, note: string): Promise<Bookmark> {
+  const res = await fetch(`/api/bookmarks?session=${encodeURIComponent(session)}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ seq, note }),
+  })
+  if (!res.ok) {
+    throw new Error(`bookmark request failed: ${res.status}`)
+  }
+  return res.json()
+}
+
+// Removes a bookmark for every client of the session
+export async function removeBookmark(id: string): Promise<void> {
+  const res = await fetch(`/api/bookmarks?id=${encodeURIComponent(id)}&session=${encodeURIComponent(session)}`, {
+    method: 'DELETE',
+  })
+  if (!res.ok) {
+    throw new Error(`bookmark request failed: ${res.status}`)
+  }
+}
+
+// Fetches the server's status, including the state of its sources
+export async function fetchStatus(): Promise<StatusResponse> {
+  const res = await fetch('/api/status')
+  if (!res.ok) {
+    throw new Error(`status request failed: ${res.status}`)
+  }
+  return res.json()
+}
@@ -3,12 +3,126 @@ export interface LogEvent {
   source: "file" | "docker";
   stream: "stdout" | "stderr" | "";
   message: string;
+  seq: number; // sequence ID assigned by the server, increasing by one per event
+  zone?: string; // zone the timestamp was written in, e.g. "Asia/Tokyo" or "+09:00"
+  details?: string[]; // continuation lines grouped by the server, e.g. stack frames
+  detailStyles?: StyleSpan[]; // ANSI colors of details, with offsets into the lines joined by "\n"
+  styles?: StyleSpan[]; // ANSI colors of message, whose escape codes the server stripped
+  level?: string;
+  fields?: Record<string, string>;
+  redactions?: string[]; // names of the redaction rules that hid part of this entry
+  correlation?: Record<string, string>; // trace and request IDs keyed by kind ("trace", "request")
+  gap?: boolean; // set by the dashboard on the marker of a gap, whose seq only records where to resume
+}
+
+// ANSI styling of a range of a message; offsets index into the JS string
+export interface StyleSpan {
+  start: number;
+  end: number;
+  fg?: string; // ANSI color name ("red", "brightBlue", ...) or "#rrggbb"
+  bg?: string;
+  bold?: boolean;
+  dim?: boolean;
+  italic?: boolean;
+  underline?: boolean;
+  inverse?: boolean;
 }
 
 export interface WebSocketMessage {
-  type: "snapshot" | "event";
-  events?: LogEvent[];
-  event?: LogEvent;
+  type: "snapshot" | "events" | "replay" | "gap" | "resumed" | "paused" | "summary" | "bookmarks" | "status" | "error";
+  events?: LogEvent[]; // "events" batches live events, in order
   sourceName?: string;
+  filter?: string; // filter the snapshot was taken with
+  first?: number; // sequence ID of the oldest event retained in memory, the cursor for older pages
+  instance?: string; // server instance the sequence IDs belong to, passed back when resuming
+  seq?: number; // "resumed": the last sequence ID replayed
+  from?: number; // "gap": sequence IDs from..to (exclusive) were lost; "summary": the events held
+  to?: number;
+  lost?: number; // "gap": the events lost that matched the filter, or all of them when unfiltered is set
+  unfiltered?: boolean; // "gap": lost wasn't filtered, as the events were deleted before they could be
+  since?: string; // "paused", "summary": when the client paused
+  count?: number; // "summary": live events that arrived while paused
+  dropped?: number; // "summary": how many of them were dropped because too many arrived
+  levels?: Record<string, number>; // "summary": the count by level
+  bookmarks?: Bookmark[]; // "snapshot", "resumed", "bookmarks": every bookmark of the session
+  status?: StatusEvent; // "status": something that happened to a source
+  statuses?: StatusEvent[]; // "snapshot", "resumed": the latest status event of each source
+  error?: string;
+}
+
+// Sent to /ws to receive only events matching a search query
+export interface SubscribeMessage {
+  type: "subscribe";
+  filter: string;
+}
+
+// Sent to /ws after reconnecting to receive only the events missed since seq;
+// also possible with /ws?resume=<seq>&instance=<instance>
+export interface ResumeMessage {
+  type: "resume";
+  seq: number;
+  instance: string;
+  filter?: string;
+}
+
+// Sent to /ws to hold live events on the server while reading, and to have
+// them sent, after a "summary" of them
+export interface PauseMessage {
+  type: "pause" | "unpause";
+}
+
+// Something that happened to a source, such as a missing file or a stopped
+// container; sent apart from log events, as a "status" message
+export interface StatusEvent {
+  time: string;
+  source: string; // file path or container name
+  kind: "file" | "docker";
+  code: "file_not_found" | "file_found" | "file_rotated" | "container_not_found" | "container_stopped" | "stream_failed" | "connection_lost" | "reconnected";
+  severity: "info" | "warning" | "error";
+  message: string;
+  details?: Record<string, string>; // e.g. the container's status or the error
+}
+
+// A mark on an event, shared by everyone in the same session
+export interface Bookmark {
+  id: string;
+  session?: string; // absent for the default session
+  seq: number;
+  note?: string; // e.g. "deploy started here"
+  author?: string;
+  createdAt: string;
+}
+
+export interface CorrelateResponse {
+  id: string;
+  events: LogEvent[];
+  truncated?: boolean; // only the newest 1000 events are returned
 }
 
+export interface HistoryResponse {
+  events: LogEvent[];
+  nextBefore?: number; // absent when there is nothing older
+  nextAfter?: number; // absent when the page reaches the newest event
+}
+
+// State of a source, from /api/status
+export interface SourceStatus {
+  name: string; // file path or container name
+  kind: "file" | "docker";
+  state: "starting" | "following" | "waiting" | "stopped" | "reconnecting" | "unknown";
+  detail?: string; // e.g. "file not found", or the status of a stopped container
+  attempt?: number; // reopen or reconnect attempts since the source was last followed
+  since: string;
+}
+
+export interface StatusResponse {
+  version: string;
+  instance: string;
+  startedAt: string;
+  uptimeSeconds: number;
+  sources: SourceStatus[];
+  ingest: { events: number; perSecond: number; lastEventAt?: string };
+  buffer: { events: number; capacity: number; first: number; next: number };
+  clients: { total: number; byTransport: Record<string, number>; paused: number; queued: number; queueSize: number };
+  store?: { dir: string; first: number; next: number };
+}
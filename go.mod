@@ -5,6 +5,8 @@ go 1.24.0
 require (
 	github.com/docker/docker v27.5.0+incompatible
 	github.com/gorilla/websocket v1.5.1
+	golang.org/x/term v0.38.0
+	golang.org/x/text v0.31.0
 )
 
 require (
@@ -30,10 +32,11 @@ require (
 	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.39.0 // indirect
 	go.opentelemetry.io/otel/metric v1.39.0 // indirect
 	go.opentelemetry.io/otel/trace v1.39.0 // indirect
-	golang.org/x/mod v0.8.0 // indirect
+	golang.org/x/mod v0.29.0 // indirect
 	golang.org/x/net v0.47.0 // indirect
+	golang.org/x/sync v0.18.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
-	golang.org/x/tools v0.6.0 // indirect
+	golang.org/x/tools v0.38.0 // indirect
 	gotest.tools/v3 v3.5.1 // indirect
 )
@@ -0,0 +1,285 @@
+package ansi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// Modes for handling escape sequences
+const (
+	// ModeSpans strips escape sequences from the message and records the SGR
+	// colors and attributes as style spans
+	ModeSpans = "spans"
+
+	// ModeStrip strips escape sequences and discards the styling
+	ModeStrip = "strip"
+)
+
+// basicColors are the names of the 8 standard ANSI colors; the bright
+// variants are prefixed with "bright" (e.g. "brightRed")
+var basicColors = [8]string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// ValidMode reports whether mode is a supported mode
+func ValidMode(mode string) bool {
+	return mode == ModeSpans || mode == ModeStrip
+}
+
+// Contains reports whether s contains an escape sequence
+func Contains(s string) bool {
+	return strings.IndexByte(s, 0x1b) >= 0
+}
+
+// Strip removes all escape sequences from s
+func Strip(s string) string {
+	if !Contains(s) {
+		return s
+	}
+	plain, _ := parse(s, false)
+	return plain
+}
+
+// Parse removes all escape sequences from s and returns the plain text
+// together with the style spans described by its SGR sequences. Span offsets
+// are in UTF-16 code units so that the browser can slice the text directly.
+func Parse(s string) (string, []models.StyleSpan) {
+	if !Contains(s) {
+		return s, nil
+	}
+	return parse(s, true)
+}
+
+// Len returns the length of s in UTF-16 code units, the unit of span offsets
+func Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// style is the SGR state in effect for a run of text
+type style struct {
+	fg, bg                            string
+	bold, dim, italic, underline, inv bool
+}
+
+func (st style) isZero() bool {
+	return st == style{}
+}
+
+func parse(s string, withSpans bool) (string, []models.StyleSpan) {
+	var (
+		b     strings.Builder
+		spans []models.StyleSpan
+		cur   style
+		start int // UTF-16 offset where cur began
+		pos   int // current UTF-16 offset in the plain text
+	)
+	b.Grow(len(s))
+
+	closeSpan := func() {
+		if withSpans && !cur.isZero() && pos > start {
+			spans = append(spans, models.StyleSpan{
+				Start:     start,
+				End:       pos,
+				Fg:        cur.fg,
+				Bg:        cur.bg,
+				Bold:      cur.bold,
+				Dim:       cur.dim,
+				Italic:    cur.italic,
+				Underline: cur.underline,
+				Inverse:   cur.inv,
+			})
+		}
+		start = pos
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != 0x1b {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+size])
+			pos += utf16.RuneLen(r)
+			i += size
+			continue
+		}
+
+		end, params, final := scanEscape(s, i)
+		if final == 'm' {
+			next := applySGR(cur, params)
+			if next != cur {
+				closeSpan()
+				cur = next
+			}
+		}
+		i = end
+	}
+	closeSpan()
+
+	return b.String(), spans
+}
+
+// scanEscape returns the end of the escape sequence starting at s[i], and for
+// CSI sequences their parameters and final byte
+func scanEscape(s string, i int) (end int, params string, final byte) {
+	if i+1 >= len(s) {
+		return len(s), "", 0
+	}
+
+	switch s[i+1] {
+	case '[': // CSI: ESC [ params final
+		j := i + 2
+		for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+			j++
+		}
+		if j >= len(s) {
+			return len(s), "", 0
+		}
+		return j + 1, s[i+2 : j], s[j]
+	case ']': // OSC: ESC ] ... BEL or ESC \
+		for j := i + 2; j < len(s); j++ {
+			if s[j] == 0x07 {
+				return j + 1, "", 0
+			}
+			if s[j] == 0x1b && j+1 < len(s) && s[j+1] == '\\' {
+				return j + 2, "", 0
+			}
+		}
+		return len(s), "", 0
+	default: // Two-byte sequences such as ESC ( B
+		if s[i+1] >= 0x20 && s[i+1] <= 0x2f && i+2 < len(s) {
+			return i + 3, "", 0
+		}
+		return i + 2, "", 0
+	}
+}
+
+// applySGR applies the parameters of an SGR sequence (ESC [ ... m) to st
+func applySGR(st style, params string) style {
+	if params == "" {
+		return style{}
+	}
+
+	codes := strings.FieldsFunc(params, func(r rune) bool { return r == ';' || r == ':' })
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case code == 0:
+			st = style{}
+		case code == 1:
+			st.bold = true
+		case code == 2:
+			st.dim = true
+		case code == 3:
+			st.italic = true
+		case code == 4:
+			st.underline = true
+		case code == 7:
+			st.inv = true
+		case code == 22:
+			st.bold, st.dim = false, false
+		case code == 23:
+			st.italic = false
+		case code == 24:
+			st.underline = false
+		case code == 27:
+			st.inv = false
+		case code >= 30 && code <= 37:
+			st.fg = basicColors[code-30]
+		case code == 39:
+			st.fg = ""
+		case code >= 40 && code <= 47:
+			st.bg = basicColors[code-40]
+		case code == 49:
+			st.bg = ""
+		case code >= 90 && code <= 97:
+			st.fg = brightColor(code - 90)
+		case code >= 100 && code <= 107:
+			st.bg = brightColor(code - 100)
+		case code == 38 || code == 48:
+			color, consumed := extendedColor(codes[i+1:])
+			i += consumed
+			if code == 38 {
+				st.fg = color
+			} else {
+				st.bg = color
+			}
+		}
+	}
+
+	return st
+}
+
+func brightColor(idx int) string {
+	name := basicColors[idx]
+	return "bright" + strings.ToUpper(name[:1]) + name[1:]
+}
+
+// extendedColor parses the arguments of a 38/48 code: 5;n for the 256-color
+// palette or 2;r;g;b for true color. It returns the color and the number of
+// arguments consumed.
+func extendedColor(args []string) (string, int) {
+	if len(args) == 0 {
+		return "", 0
+	}
+
+	switch args[0] {
+	case "5":
+		if len(args) < 2 {
+			return "", len(args)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || n > 255 {
+			return "", 2
+		}
+		return paletteColor(n), 2
+	case "2":
+		if len(args) < 4 {
+			return "", len(args)
+		}
+		r, _ := strconv.Atoi(args[1])
+		g, _ := strconv.Atoi(args[2])
+		b, _ := strconv.Atoi(args[3])
+		return hexColor(r, g, b), 4
+	}
+	return "", 1
+}
+
+// paletteColor maps an index of the xterm 256-color palette to a color.
+// The first 16 entries are the theme-dependent named colors.
+func paletteColor(n int) string {
+	switch {
+	case n < 8:
+		return basicColors[n]
+	case n < 16:
+		return brightColor(n - 8)
+	case n < 232:
+		n -= 16
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+		return hexColor(levels[n/36], levels[(n/6)%6], levels[n%6])
+	default:
+		gray := 8 + (n-232)*10
+		return hexColor(gray, gray, gray)
+	}
+}
+
+func hexColor(r, g, b int) string {
+	clamp := func(v int) int {
+		if v < 0 {
+			return 0
+		}
+		if v > 255 {
+			return 255
+		}
+		return v
+	}
+	return fmt.Sprintf("#%02x%02x%02x", clamp(r), clamp(g), clamp(b))
+}
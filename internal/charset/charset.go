@@ -0,0 +1,111 @@
+package charset
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Byte order marks
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Lookup returns the encoding with the given name, e.g. "shift_jis",
+// "utf-16le", "euc-jp" or "windows-1252". Empty names and UTF-8 return nil,
+// meaning the input needs no conversion.
+func Lookup(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	case "utf-16le", "utf16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), nil
+	case "utf-16be", "utf16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil
+	case "utf-16", "utf16":
+		// Without a BOM, UTF-16 is assumed to be little endian as written on Windows
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), nil
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	if enc == unicode.UTF8 {
+		return nil, nil
+	}
+	return enc, nil
+}
+
+// DetectBOM looks for a byte order mark at the start of data and returns the
+// encoding it announces and its length in bytes. ok is false when there is none.
+func DetectBOM(data []byte) (enc encoding.Encoding, size int, ok bool) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return nil, len(bomUTF8), true
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), len(bomUTF16LE), true
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), len(bomUTF16BE), true
+	}
+	return nil, 0, false
+}
+
+// LineOriented reports whether a newline is encoded as the single byte '\n'
+// and that byte never appears inside another character, so raw bytes can be
+// split into lines before decoding. This holds for UTF-8 and the legacy
+// ASCII-compatible encodings, but not for UTF-16.
+func LineOriented(enc encoding.Encoding) bool {
+	if enc == nil {
+		return true
+	}
+	nl, err := enc.NewEncoder().Bytes([]byte("\n"))
+	return err == nil && bytes.Equal(nl, []byte("\n"))
+}
+
+// UnitSize returns the size in bytes of the code units of enc, such as 2 for
+// UTF-16, which reads into the middle of a file must be aligned to
+func UnitSize(enc encoding.Encoding) int {
+	if enc == nil {
+		return 1
+	}
+	// Subtracting cancels out a BOM the encoder may write first
+	one, err1 := enc.NewEncoder().Bytes([]byte("a"))
+	two, err2 := enc.NewEncoder().Bytes([]byte("aa"))
+	if err1 != nil || err2 != nil || len(two) <= len(one) {
+		return 1
+	}
+	return len(two) - len(one)
+}
+
+// NewReader returns a reader that converts r from enc to UTF-8
+func NewReader(r io.Reader, enc encoding.Encoding) io.Reader {
+	if enc == nil {
+		return r
+	}
+	return transform.NewReader(r, enc.NewDecoder())
+}
+
+// Decode converts data from enc to UTF-8. Invalid sequences are replaced
+// with U+FFFD, so the result is always valid UTF-8.
+func Decode(data []byte, enc encoding.Encoding) string {
+	if enc != nil {
+		if decoded, err := enc.NewDecoder().Bytes(data); err == nil {
+			data = decoded
+		}
+	}
+	return Sanitize(string(data))
+}
+
+// Sanitize replaces invalid UTF-8 sequences in s with U+FFFD
+func Sanitize(s string) string {
+	return strings.ToValidUTF8(s, "�")
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/ansi"
+	"github.com/monstarlab/shepai/internal/collector"
+	"github.com/monstarlab/shepai/internal/config"
+	"github.com/monstarlab/shepai/internal/metrics"
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/parser"
+	"github.com/monstarlab/shepai/internal/pipeline"
+	"github.com/monstarlab/shepai/internal/redact"
+	"github.com/monstarlab/shepai/internal/server"
+	"github.com/monstarlab/shepai/internal/store"
+)
+
+// Version is the shepai version, reported by /api/status
+var Version = "dev"
+
+// parseArgs parses flags and returns the positional arguments.
+// The Go flag package stops at the first non-flag argument, so parsing is
+// repeated after each positional argument. This allows flags on either side:
+// shepai file path.log --port 8080 or shepai file --port=8080 path.log
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+			os.Exit(1)
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// loadConfig loads the config file, falling back to ./shepai.json or an empty config
+func loadConfig(configPath string) *config.Config {
+	cfg, err := config.LoadOrDefault(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// collectorOptions builds the collector options for the given source.
+// Non-empty tz and ansiMode flags override the config.
+func collectorOptions(cfg *config.Config, source, tz, ansiMode string) collector.Options {
+	if tz == "" {
+		tz = cfg.TimezoneFor(source)
+	}
+	loc := loadLocation(tz)
+
+	parsers, err := parser.NewChain(cfg, loc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if ansiMode == "" {
+		ansiMode = cfg.ANSI
+	}
+	if ansiMode != "" && !ansi.ValidMode(ansiMode) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --ansi mode %q (expected %q or %q)\n", ansiMode, ansi.ModeSpans, ansi.ModeStrip)
+		os.Exit(1)
+	}
+
+	return collector.Options{
+		Parsers:          parsers,
+		Location:         loc,
+		TimestampLayouts: cfg.TimestampLayouts,
+		ANSIMode:         ansiMode,
+		Encoding:         cfg.EncodingFor(source),
+		Metrics:          metrics.New(),
+	}
+}
+
+// buildPipeline wraps the collector with the processing stages enabled in the config
+func buildPipeline(c models.LogCollector, cfg *config.Config) models.LogCollector {
+	var stages []pipeline.Stage
+
+	if !cfg.Multiline.Disabled {
+		multiline, err := pipeline.NewMultiline(pipeline.MultilineConfig{
+			Rules:        cfg.Multiline.Rules,
+			FlushTimeout: time.Duration(cfg.Multiline.FlushTimeout),
+			MaxLines:     cfg.Multiline.MaxLines,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+			os.Exit(1)
+		}
+		stages = append(stages, multiline)
+	}
+
+	if !cfg.Redact.Disabled {
+		redactor, err := newRedactor(cfg.Redact)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+			os.Exit(1)
+		}
+		stages = append(stages, pipeline.NewRedact(redactor))
+	}
+
+	return pipeline.Wrap(c, stages...)
+}
+
+// newRedactor builds the redactor from the config's redaction settings
+func newRedactor(cfg config.RedactConfig) (*redact.Redactor, error) {
+	rules := make([]redact.Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rule, err := redact.NewRule(r.Name, r.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return redact.New(redact.Config{Disable: cfg.Disable, Rules: rules})
+}
+
+// serverOptions builds the server options for the given source, sharing
+// the collector's metrics. A non-empty storeDir flag overrides the config's
+// store directory.
+func serverOptions(cfg *config.Config, source, storeDir string, collectorOpts collector.Options, metricsOnly bool) server.Options {
+	opts := server.Options{
+		QueueSize:   cfg.WebSocket.QueueSize,
+		Overflow:    cfg.WebSocket.Overflow,
+		Metrics:     collectorOpts.Metrics,
+		MetricsOnly: metricsOnly,
+		Version:     Version,
+	}
+
+	if storeDir == "" {
+		storeDir = cfg.Store.Dir
+	}
+	if storeDir != "" {
+		st, err := store.Open(store.Options{
+			Dir:     store.DirFor(storeDir, source),
+			MaxSize: int64(cfg.Store.MaxSize),
+			MaxAge:  time.Duration(cfg.Store.MaxAge),
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening store: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Store = st
+	}
+
+	return opts
+}
+
+// loadLocation resolves a --tz value. An empty value keeps the default (UTC).
+func loadLocation(tz string) *time.Location {
+	if tz == "" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid timezone %q: %v\n", tz, err)
+		os.Exit(1)
+	}
+	return loc
+}
@@ -10,34 +10,24 @@ import (
 )
 
 func HandleDockerCommand(args []string) {
+	handleDocker(args, false)
+}
+
+// handleDocker runs the docker command, showing its logs in the web dashboard,
+// or in the terminal viewer when tui is set
+func handleDocker(args []string, tui bool) {
 	fs := flag.NewFlagSet("docker", flag.ExitOnError)
 	port := fs.Int("port", 4040, "Port for web dashboard")
+	configPath := fs.String("config", "", "Path to config file (default: ./shepai.json if present)")
+	tz := fs.String("tz", "", "Timezone for timestamps without an offset, e.g. Asia/Tokyo (default: UTC)")
+	ansiMode := fs.String("ansi", "", "ANSI color handling: spans (keep colors) or strip (default: spans)")
+	storeDir := fs.String("store", "", "Directory to keep log history in across restarts (default: memory only)")
+	metricsOnly := fs.Bool("metrics-only", false, "Serve only Prometheus metrics at /metrics, without the web dashboard")
+	stdout := addStdoutFlags(fs)
 
-	// Parse flags - this handles flags before positional args
-	if err := fs.Parse(args); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Check for --port flag after positional args (Go flag package stops at first non-flag)
-	// This allows: shepai docker container --port 8080 or --port=8080
-	for i, arg := range args {
-		if arg == "--port" && i+1 < len(args) {
-			// Parse the port value manually (--port 8080)
-			var portVal int
-			if _, err := fmt.Sscanf(args[i+1], "%d", &portVal); err == nil {
-				*port = portVal
-			}
-		} else if len(arg) > 7 && arg[:7] == "--port=" {
-			// Parse the port value manually (--port=8080)
-			var portVal int
-			if _, err := fmt.Sscanf(arg[7:], "%d", &portVal); err == nil {
-				*port = portVal
-			}
-		}
-	}
+	args = parseArgs(fs, args)
 
-	if fs.NArg() < 1 {
+	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "Error: container name or ID is required\n")
 		fmt.Fprintf(os.Stderr, "Usage: shepai docker <container_name_or_id> [flags]\n")
 		fmt.Fprintf(os.Stderr, "  Examples:\n")
@@ -47,9 +37,12 @@ func HandleDockerCommand(args []string) {
 		os.Exit(1)
 	}
 
-	containerIdentifier := fs.Arg(0)
+	containerIdentifier := args[0]
+
+	cfg := loadConfig(*configPath)
+	opts := collectorOptions(cfg, containerIdentifier, *tz, *ansiMode)
 
-	dockerCollector, err := collector.NewDockerCollector(containerIdentifier)
+	dockerCollector, err := collector.NewDockerCollector(containerIdentifier, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating Docker collector: %v\n", err)
 		fmt.Fprintf(os.Stderr, "Make sure Docker is running and the container exists\n")
@@ -57,10 +50,19 @@ func HandleDockerCommand(args []string) {
 		os.Exit(1)
 	}
 
+	if tui {
+		runTUI(buildPipeline(dockerCollector, cfg), stdout)
+		return
+	}
+	if stdout.enabled {
+		runStdout(buildPipeline(dockerCollector, cfg), stdout)
+		return
+	}
+
 	fmt.Printf("Streaming logs from container: %s\n", containerIdentifier)
 	fmt.Printf("Press Ctrl+C to stop\n\n")
 
-	if err := server.Start(*port, dockerCollector); err != nil {
+	if err := server.Start(*port, buildPipeline(dockerCollector, cfg), serverOptions(cfg, containerIdentifier, *storeDir, opts, *metricsOnly)); err != nil {
 		fmt.Fprintf(os.Stderr, "Error starting server: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// HandleExportCommand downloads retained events from a running shepai
+// through /api/export, to a file or stdout
+func HandleExportCommand(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	port := fs.Int("port", 4040, "Port of the running shepai")
+	format := fs.String("format", "ndjson", "Export format: ndjson, csv or txt")
+	q := fs.String("query", "", "Search query selecting the events to export (default: all)")
+	from := fs.String("from", "", "Oldest time to export, e.g. 2026-10-16T10:00:00Z or 1h (ago)")
+	to := fs.String("to", "", "Time to export up to (exclusive), in the same formats as --from")
+	output := fs.String("o", "", "File to write (default: stdout)")
+
+	args = parseArgs(fs, args)
+
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected argument %q\n", args[0])
+		fmt.Fprintf(os.Stderr, "Usage: shepai export [--query q] [--from t] [--to t] [--format ndjson|csv|txt] [-o file]\n")
+		os.Exit(1)
+	}
+
+	params := url.Values{}
+	params.Set("format", *format)
+	if *q != "" {
+		params.Set("query", *q)
+	}
+	if *from != "" {
+		params.Set("from", *from)
+	}
+	if *to != "" {
+		params.Set("to", *to)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/api/export?%s", *port, params.Encode()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not reach shepai on port %d: %v\n", *port, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Error: %s\n", strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting events: %v\n", err)
+		os.Exit(1)
+	}
+}
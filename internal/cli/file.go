@@ -10,56 +10,62 @@ import (
 )
 
 func HandleFileCommand(args []string) {
+	handleFile(args, false)
+}
+
+// handleFile runs the file command, showing its logs in the web dashboard,
+// or in the terminal viewer when tui is set
+func handleFile(args []string, tui bool) {
 	fs := flag.NewFlagSet("file", flag.ExitOnError)
 	port := fs.Int("port", 4040, "Port for web dashboard")
+	configPath := fs.String("config", "", "Path to config file (default: ./shepai.json if present)")
+	tz := fs.String("tz", "", "Timezone for timestamps without an offset, e.g. Asia/Tokyo (default: UTC)")
+	ansiMode := fs.String("ansi", "", "ANSI color handling: spans (keep colors) or strip (default: spans)")
+	storeDir := fs.String("store", "", "Directory to keep log history in across restarts (default: memory only)")
+	metricsOnly := fs.Bool("metrics-only", false, "Serve only Prometheus metrics at /metrics, without the web dashboard")
+	encoding := fs.String("encoding", "", "Character encoding of the file, e.g. shift_jis or utf-16le (default: utf-8, or as given by a BOM)")
+	stdout := addStdoutFlags(fs)
 
-	// Parse flags - this handles flags before positional args
-	if err := fs.Parse(args); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Check for --port flag after positional args (Go flag package stops at first non-flag)
-	// This allows: shepai file path.log --port 8080 or --port=8080
-	for i, arg := range args {
-		if arg == "--port" && i+1 < len(args) {
-			// Parse the port value manually (--port 8080)
-			var portVal int
-			if _, err := fmt.Sscanf(args[i+1], "%d", &portVal); err == nil {
-				*port = portVal
-			}
-		} else if len(arg) > 7 && arg[:7] == "--port=" {
-			// Parse the port value manually (--port=8080)
-			var portVal int
-			if _, err := fmt.Sscanf(arg[7:], "%d", &portVal); err == nil {
-				*port = portVal
-			}
-		}
-	}
+	args = parseArgs(fs, args)
 
-	if fs.NArg() < 1 {
+	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "Error: file path is required\n")
 		fmt.Fprintf(os.Stderr, "Usage: shepai file <path> [flags]\n")
 		os.Exit(1)
 	}
 
-	filePath := fs.Arg(0)
+	filePath := args[0]
 
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		fmt.Fprintf(os.Stderr, "Error: file does not exist: %s\n", filePath)
 		os.Exit(1)
 	}
 
-	fileCollector, err := collector.NewFileCollector(filePath)
+	cfg := loadConfig(*configPath)
+	opts := collectorOptions(cfg, filePath, *tz, *ansiMode)
+	if *encoding != "" {
+		opts.Encoding = *encoding
+	}
+
+	fileCollector, err := collector.NewFileCollector(filePath, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating file collector: %v\n", err)
 		os.Exit(1)
 	}
 
+	if tui {
+		runTUI(buildPipeline(fileCollector, cfg), stdout)
+		return
+	}
+	if stdout.enabled {
+		runStdout(buildPipeline(fileCollector, cfg), stdout)
+		return
+	}
+
 	fmt.Printf("Streaming logs from: %s\n", filePath)
 	fmt.Printf("Press Ctrl+C to stop\n\n")
 	
-	if err := server.Start(*port, fileCollector); err != nil {
+	if err := server.Start(*port, buildPipeline(fileCollector, cfg), serverOptions(cfg, filePath, *storeDir, opts, *metricsOnly)); err != nil {
 		fmt.Fprintf(os.Stderr, "Error starting server: %v\n", err)
 		os.Exit(1)
 	}
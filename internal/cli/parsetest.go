@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/config"
+	"github.com/monstarlab/shepai/internal/parser"
+)
+
+// HandleParseTestCommand runs a single parser rule against a sample file and
+// prints how each line was parsed, so rules can be debugged without streaming
+func HandleParseTestCommand(args []string) {
+	fs := flag.NewFlagSet("parse-test", flag.ExitOnError)
+	configPath := fs.String("config", config.DefaultPath, "Path to config file")
+	tz := fs.String("tz", "", "Timezone for timestamps without an offset, e.g. Asia/Tokyo (default: UTC)")
+
+	args = parseArgs(fs, args)
+
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "Error: rule name and sample file are required\n")
+		fmt.Fprintf(os.Stderr, "Usage: shepai parse-test <rule> <sample-file> [--config path]\n")
+		os.Exit(1)
+	}
+
+	ruleName, samplePath := args[0], args[1]
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	rule, ok := cfg.Parser(ruleName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: no parser named %q in %s\n", ruleName, *configPath)
+		os.Exit(1)
+	}
+
+	lib, err := parser.NewGrokLibrary(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading grok patterns: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *tz == "" {
+		*tz = cfg.Timezone
+	}
+
+	p, err := parser.New(rule, lib, loadLocation(*tz))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	file, err := os.Open(samplePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening sample file: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	matched, total := 0, 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		total++
+		line := scanner.Text()
+
+		res, ok := p.Parse(line)
+		if !ok {
+			fmt.Printf("%4d  NO MATCH  %s\n", total, line)
+			continue
+		}
+		matched++
+
+		fmt.Printf("%4d  MATCH     %s\n", total, line)
+		if !res.Timestamp.IsZero() {
+			fmt.Printf("      ts     = %s\n", res.Timestamp.Format(time.RFC3339Nano))
+		}
+		if res.TimestampErr != nil {
+			fmt.Printf("      ts     ! %v\n", res.TimestampErr)
+		}
+		if res.Level != "" {
+			fmt.Printf("      level  = %s\n", res.Level)
+		}
+		if res.Message != "" {
+			fmt.Printf("      msg    = %s\n", res.Message)
+		}
+
+		keys := make([]string, 0, len(res.Fields))
+		for k := range res.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("      %-6s = %s\n", k, res.Fields[k])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading sample file: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n%d/%d lines matched rule %q\n", matched, total, ruleName)
+}
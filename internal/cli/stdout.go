@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/printer"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+// stdoutFlags are the flags of the terminal output mode, which prints
+// events instead of serving the dashboard
+type stdoutFlags struct {
+	enabled bool
+	filter  string
+	output  string
+}
+
+func addStdoutFlags(fs *flag.FlagSet) *stdoutFlags {
+	f := &stdoutFlags{}
+	fs.BoolVar(&f.enabled, "no-ui", false, "Print logs to the terminal instead of serving the web dashboard")
+	fs.BoolVar(&f.enabled, "stdout", false, "Same as --no-ui")
+	fs.StringVar(&f.filter, "filter", "", "With --no-ui or tui, show only events matching a search query, e.g. level:error")
+	fs.StringVar(&f.output, "output", printer.FormatText, "With --no-ui, output format: text or json")
+	return f
+}
+
+// runStdout prints the collector's snapshot and then its live events to
+// stdout until interrupted
+func runStdout(c models.LogCollector, flags *stdoutFlags) {
+	if !printer.ValidFormat(flags.output) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --output %q (expected %q or %q)\n", flags.output, printer.FormatText, printer.FormatJSON)
+		os.Exit(1)
+	}
+
+	filter, err := query.Parse(flags.filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --filter: %v\n", err)
+		os.Exit(1)
+	}
+
+	p := printer.New(os.Stdout, printer.Options{
+		Format: flags.output,
+		Color:  flags.output == printer.FormatText && printer.ColorEnabled(os.Stdout),
+		Filter: filter,
+	})
+
+	// Number events as the server would
+	var seq int64
+	printEvent := func(event models.LogEvent) error {
+		event.Seq = seq
+		seq++
+		return p.Print(event)
+	}
+
+	snapshot, err := c.GetSnapshot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to get snapshot: %v\n", err)
+		os.Exit(1)
+	}
+	for _, event := range snapshot {
+		printEvent(event)
+	}
+
+	// Status events go to stderr, so they don't mix with the output
+	statuses := make(chan models.StatusEvent, 16)
+	if n, ok := c.(models.StatusNotifier); ok {
+		n.NotifyStatus(statuses)
+	}
+
+	events := make(chan models.LogEvent, 100)
+	if err := c.Start(events); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to start collector: %v\n", err)
+		os.Exit(1)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		select {
+		case event := <-events:
+			if err := printEvent(event); err != nil {
+				c.Stop()
+				return
+			}
+		case status := <-statuses:
+			fmt.Fprintf(os.Stderr, "shepai: %s\n", status.Message)
+		case <-sigChan:
+			if err := c.Stop(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error stopping collector: %v\n", err)
+			}
+			return
+		}
+	}
+}
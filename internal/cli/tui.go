@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/tui"
+)
+
+// HandleTUICommand shows the logs of a file or container in the terminal
+// viewer: shepai tui file <path> or shepai tui docker <container>
+func HandleTUICommand(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Error: source is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: shepai tui file <path> [flags]\n")
+		fmt.Fprintf(os.Stderr, "       shepai tui docker <container_name_or_id> [flags]\n")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "file":
+		handleFile(args[1:], true)
+	case "docker":
+		handleDocker(args[1:], true)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown source %q (expected file or docker)\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// runTUI shows the collector's logs in the terminal viewer until the user
+// quits, starting with the --filter query as the search
+func runTUI(c models.LogCollector, flags *stdoutFlags) {
+	if err := tui.Run(c, tui.Options{Filter: flags.filter}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/monstarlab/shepai/internal/charset"
 	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/timestamp"
 )
 
 const (
@@ -20,12 +23,15 @@ const (
 type DockerCollector struct {
 	containerName string
 	client        *client.Client
+	opts          Options
+	detector      *timestamp.Detector
+	state         *sourceState
 	stopChan      chan struct{}
 }
 
 // NewDockerCollector creates a new Docker collector.
 // containerIdentifier can be either a container name or container ID (full or short).
-func NewDockerCollector(containerIdentifier string) (*DockerCollector, error) {
+func NewDockerCollector(containerIdentifier string, opts Options) (*DockerCollector, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
@@ -43,9 +49,17 @@ func NewDockerCollector(containerIdentifier string) (*DockerCollector, error) {
 		containerName = containerName[1:]
 	}
 
+	detector, err := opts.newDetector()
+	if err != nil {
+		return nil, err
+	}
+
 	return &DockerCollector{
 		containerName: containerName,
 		client:        cli,
+		opts:          opts,
+		detector:      detector,
+		state:         newSourceState(containerName, "docker"),
 		stopChan:      make(chan struct{}),
 	}, nil
 }
@@ -82,6 +96,9 @@ func (d *DockerCollector) Start(ch chan<- models.LogEvent) error {
 func (d *DockerCollector) streamWithReconnect(ch chan<- models.LogEvent) {
 	reconnectDelay := 2 * time.Second
 	maxReconnectDelay := 30 * time.Second
+	connected := false // every connection after the first is a reconnect
+	failed := false    // a problem was reported since the last connection
+	tried := false     // logs were requested before
 
 	for {
 		select {
@@ -92,13 +109,12 @@ func (d *DockerCollector) streamWithReconnect(ch chan<- models.LogEvent) {
 			ctx := context.Background()
 			containerInfo, err := d.client.ContainerInspect(ctx, d.containerName)
 			if err != nil {
-				// Container not found - send error message and wait
-				ch <- models.LogEvent{
-					Timestamp: time.Now(),
-					Source:    "docker",
-					Stream:    "stderr",
-					Message:   fmt.Sprintf("[shepai] Container '%s' not found. Waiting for container to start...", d.containerName),
-				}
+				d.state.set(models.SourceWaiting, "container not found")
+
+				// Container not found - send status event and wait
+				failed = true
+				d.state.report(models.StatusContainerNotFound, models.SeverityWarning,
+					fmt.Sprintf("Container '%s' not found. Waiting for container to start...", d.containerName), nil)
 				time.Sleep(reconnectDelay)
 				if reconnectDelay < maxReconnectDelay {
 					reconnectDelay *= 2
@@ -108,13 +124,13 @@ func (d *DockerCollector) streamWithReconnect(ch chan<- models.LogEvent) {
 
 			// Check if container is actually running (not just exists)
 			if !containerInfo.State.Running {
+				d.state.set(models.SourceStopped, containerInfo.State.Status)
+
 				// Container exists but is not running
-				ch <- models.LogEvent{
-					Timestamp: time.Now(),
-					Source:    "docker",
-					Stream:    "stderr",
-					Message:   fmt.Sprintf("[shepai] Container '%s' is not running (status: %s). Waiting for container to start...", d.containerName, containerInfo.State.Status),
-				}
+				failed = true
+				d.state.report(models.StatusContainerStopped, models.SeverityWarning,
+					fmt.Sprintf("Container '%s' is not running (status: %s). Waiting for container to start...", d.containerName, containerInfo.State.Status),
+					map[string]string{"status": containerInfo.State.Status})
 				time.Sleep(reconnectDelay)
 				if reconnectDelay < maxReconnectDelay {
 					reconnectDelay *= 2
@@ -134,31 +150,48 @@ func (d *DockerCollector) streamWithReconnect(ch chan<- models.LogEvent) {
 				Follow:     true,
 			}
 
+			// Any stream after the first is a reconnect attempt
+			if tried {
+				d.state.retry()
+			}
+			tried = true
+
 			reader, err := d.client.ContainerLogs(ctx, d.containerName, options)
 			if err != nil {
+				d.state.set(models.SourceReconnecting, err.Error())
+
 				// Failed to get logs - container might be stopping/restarting
-				ch <- models.LogEvent{
-					Timestamp: time.Now(),
-					Source:    "docker",
-					Stream:    "stderr",
-					Message:   fmt.Sprintf("[shepai] Failed to stream logs from container '%s': %v. Retrying...", d.containerName, err),
-				}
+				failed = true
+				d.state.report(models.StatusStreamFailed, models.SeverityError,
+					fmt.Sprintf("Failed to stream logs from container '%s': %v. Retrying...", d.containerName, err),
+					map[string]string{"error": err.Error()})
 				time.Sleep(reconnectDelay)
 				continue
 			}
+			if connected {
+				d.opts.Metrics.Reconnect(d.containerName)
+			}
+			if failed {
+				d.state.report(models.StatusReconnected, models.SeverityInfo,
+					fmt.Sprintf("Connected to container '%s'. Resuming log streaming...", d.containerName), nil)
+			}
+			connected, failed = true, false
+			d.state.set(models.SourceFollowing, "")
 
 			// Stream logs until connection is lost
 			streamErr := d.streamLogs(reader, ch)
 			reader.Close()
 
 			if streamErr != nil && streamErr != io.EOF {
+				d.state.set(models.SourceReconnecting, "connection lost")
+
 				// Connection lost - container might have stopped/restarted
-				ch <- models.LogEvent{
-					Timestamp: time.Now(),
-					Source:    "docker",
-					Stream:    "stderr",
-					Message:   fmt.Sprintf("[shepai] Connection to container '%s' lost. Attempting to reconnect...", d.containerName),
-				}
+				failed = true
+				d.state.report(models.StatusConnectionLost, models.SeverityWarning,
+					fmt.Sprintf("Connection to container '%s' lost. Attempting to reconnect...", d.containerName),
+					map[string]string{"error": streamErr.Error()})
+			} else {
+				d.state.set(models.SourceReconnecting, "log stream ended")
 			}
 
 			// Wait before reconnecting
@@ -204,6 +237,18 @@ func (d *DockerCollector) Stop() error {
 	return nil
 }
 
+// NotifyStatus sends the container's status events to ch, such as the
+// container stopping or the connection to it being lost
+func (d *DockerCollector) NotifyStatus(ch chan<- models.StatusEvent) {
+	d.state.setNotify(ch)
+}
+
+// SourceStatus reports whether the container is being followed, waited
+// for or reconnected to
+func (d *DockerCollector) SourceStatus() models.SourceStatus {
+	return d.state.get()
+}
+
 // GetSourceName returns the container name or ID
 func (d *DockerCollector) GetSourceName() string {
 	return d.containerName
@@ -255,21 +300,30 @@ func (d *DockerCollector) parseDockerLogs(reader io.Reader) ([]models.LogEvent,
 			read += n
 		}
 
-		line := string(lineData[:read])
+		line := charset.Sanitize(string(lineData[:read]))
 
 		timestamp := time.Now()
+		zone := ""
 		message := line
-		if parsedTime, msg := d.parseTimestamp(line); !parsedTime.IsZero() {
-			timestamp = parsedTime
-			message = msg
+		if m, ok := d.detector.Detect(line); ok && m.Start == 0 {
+			// Strip the timestamp Docker prepends, and the space after it
+			timestamp = m.Time
+			zone = zoneOf(m.Time)
+			message = strings.TrimPrefix(line[m.End:], " ")
 		}
 
-		events = append(events, models.LogEvent{
+		event := models.LogEvent{
 			Timestamp: timestamp,
 			Source:    "docker",
 			Stream:    stream,
 			Message:   message,
-		})
+			Zone:      zone,
+		}
+		d.opts.applyANSI(&event)
+		d.opts.applyParsers(d.containerName, &event)
+		d.opts.applyCorrelation(&event)
+
+		events = append(events, event)
 	}
 
 	return events, nil
@@ -299,49 +353,32 @@ func (d *DockerCollector) parseDockerLogChunk(chunk []byte) []models.LogEvent {
 			break
 		}
 
-		line := string(chunk[pos : pos+size])
+		line := charset.Sanitize(string(chunk[pos : pos+size]))
 		pos += size
 
 		timestamp := time.Now()
+		zone := ""
 		message := line
-		if parsedTime, msg := d.parseTimestamp(line); !parsedTime.IsZero() {
-			timestamp = parsedTime
-			message = msg
+		if m, ok := d.detector.Detect(line); ok && m.Start == 0 {
+			// Strip the timestamp Docker prepends, and the space after it
+			timestamp = m.Time
+			zone = zoneOf(m.Time)
+			message = strings.TrimPrefix(line[m.End:], " ")
 		}
 
-		events = append(events, models.LogEvent{
+		event := models.LogEvent{
 			Timestamp: timestamp,
 			Source:    "docker",
 			Stream:    stream,
 			Message:   message,
-		})
-	}
-
-	return events
-}
-
-// parseTimestamp attempts to parse Docker timestamp format.
-func (d *DockerCollector) parseTimestamp(line string) (time.Time, string) {
-	formats := []string{
-		time.RFC3339Nano,
-		time.RFC3339,
-		"2006-01-02T15:04:05.000000000Z",
-		"2006-01-02T15:04:05.000000Z",
-		"2006-01-02T15:04:05.000Z",
-	}
-
-	for _, format := range formats {
-		if len(line) >= len(format) {
-			if t, err := time.Parse(format, line[:len(format)]); err == nil {
-				// Skip timestamp and space
-				msgStart := len(format)
-				if msgStart < len(line) && line[msgStart] == ' ' {
-					msgStart++
-				}
-				return t, line[msgStart:]
-			}
+			Zone:      zone,
 		}
+		d.opts.applyANSI(&event)
+		d.opts.applyParsers(d.containerName, &event)
+		d.opts.applyCorrelation(&event)
+
+		events = append(events, event)
 	}
 
-	return time.Time{}, line
+	return events
 }
@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
+	"golang.org/x/text/encoding"
+
+	"github.com/monstarlab/shepai/internal/charset"
 	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/timestamp"
 )
 
 const (
@@ -18,19 +23,47 @@ const (
 // FileCollector collects logs from a file
 type FileCollector struct {
 	filePath string
+	opts     Options
+	encoding encoding.Encoding // nil for UTF-8
+	detector *timestamp.Detector
+	state    *sourceState
 	stopChan chan struct{}
+
+	// startPos is where following begins: the end of the file when the
+	// snapshot was taken, so snapshot lines aren't sent again
+	startPos int64
+}
+
+// textFormat describes how the bytes of a file are decoded
+type textFormat struct {
+	enc encoding.Encoding // nil for UTF-8
+	bom int64             // length of the byte order mark to skip
 }
 
 // NewFileCollector creates a new file collector
-func NewFileCollector(filePath string) (*FileCollector, error) {
+func NewFileCollector(filePath string, opts Options) (*FileCollector, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	file.Close()
 
+	enc, err := charset.Lookup(opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	detector, err := opts.newDetector()
+	if err != nil {
+		return nil, err
+	}
+
 	return &FileCollector{
 		filePath: filePath,
+		opts:     opts,
+		encoding: enc,
+		detector: detector,
+		state:    newSourceState(filePath, "file"),
 		stopChan: make(chan struct{}),
 	}, nil
 }
@@ -50,10 +83,20 @@ func (f *FileCollector) GetSnapshot() ([]models.LogEvent, error) {
 	}
 
 	fileSize := stat.Size()
+	f.startPos = fileSize
 	if fileSize == 0 {
 		return []models.LogEvent{}, nil
 	}
 
+	format := f.textFormat(file)
+	if !charset.LineOriented(format.enc) {
+		lines, err := f.tailDecoded(file, format, fileSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		return f.snapshotEvents(lines), nil
+	}
+
 	// Read in chunks from the end
 	chunkSize := int64(8192)
 	if fileSize < chunkSize {
@@ -118,12 +161,64 @@ func (f *FileCollector) GetSnapshot() ([]models.LogEvent, error) {
 		}
 	}
 
+	// The first line of the file starts with the UTF-8 byte order mark, if any
+	if pos == 0 && len(lines) > 0 {
+		lines[0] = strings.TrimPrefix(lines[0], "\uFEFF")
+		if lines[0] == "" {
+			lines = lines[1:]
+		}
+	}
+
 	// Trim to exact number of lines
 	if len(lines) > DefaultSnapshotLines {
 		lines = lines[len(lines)-DefaultSnapshotLines:]
 	}
 
-	// Convert to LogEvent
+	for i, line := range lines {
+		lines[i] = charset.Decode([]byte(line), format.enc)
+	}
+
+	return f.snapshotEvents(lines), nil
+}
+
+// tailDecoded returns the last DefaultSnapshotLines lines of a file whose
+// lines can't be found by scanning raw bytes backwards, such as UTF-16. It
+// decodes a window at the end of the file, starting on a code unit after
+// the BOM, and doubles the window until it holds enough lines.
+func (f *FileCollector) tailDecoded(file *os.File, format textFormat, size int64) ([]string, error) {
+	unit := int64(charset.UnitSize(format.enc))
+	size -= (size - format.bom) % unit // a character still being written
+
+	for window := int64(64 * 1024); ; window *= 2 {
+		start := max(format.bom, size-window)
+		start -= (start - format.bom) % unit
+
+		buf := make([]byte, size-start)
+		if _, err := file.ReadAt(buf, start); err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		text := charset.Decode(buf, format.enc)
+		if start > format.bom {
+			// The window most likely starts inside a line
+			_, text, _ = strings.Cut(text, "\n")
+		}
+
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSuffix(line, "\r"); line != "" {
+				lines = append(lines, line)
+			}
+		}
+
+		if len(lines) >= DefaultSnapshotLines || start == format.bom {
+			return lines[max(0, len(lines)-DefaultSnapshotLines):], nil
+		}
+	}
+}
+
+// snapshotEvents converts snapshot lines to events
+func (f *FileCollector) snapshotEvents(lines []string) []models.LogEvent {
 	events := make([]models.LogEvent, 0, len(lines))
 	now := time.Now()
 
@@ -136,40 +231,50 @@ func (f *FileCollector) GetSnapshot() ([]models.LogEvent, error) {
 			Message:   line,
 		}
 
+		f.opts.applyANSI(&event)
+
 		// Try to extract timestamp from log line
-		if parsedTime := f.parseTimestampFromLine(line); !parsedTime.IsZero() {
-			event.Timestamp = parsedTime
+		if m, ok := f.detector.Detect(event.Message); ok {
+			event.Timestamp = m.Time
+			event.Zone = zoneOf(m.Time)
 		}
+		f.opts.applyParsers(f.filePath, &event)
+		f.opts.applyCorrelation(&event)
 
 		events = append(events, event)
 	}
 
-	return events, nil
+	return events
 }
 
 // Start begins following the file and sending events to the channel
 func (f *FileCollector) Start(ch chan<- models.LogEvent) error {
 	go func() {
-		lastPos := int64(0)
+		lastPos := f.startPos
 		reconnectDelay := 2 * time.Second
 		maxReconnectDelay := 30 * time.Second
 		fileWasDeleted := false
+		lost := false // the file wasn't followed on the last pass
 
 		for {
 			select {
 			case <-f.stopChan:
 				return
 			default:
+				// Opening the file again once lost is a reopen attempt
+				if lost {
+					f.state.retry()
+				}
+
 				file, err := os.Open(f.filePath)
 				if err != nil {
-					// File not found - send status message
+					lost = true
+					f.state.set(models.SourceWaiting, "file not found")
+
+					// File not found - send status event
 					if !fileWasDeleted {
-						ch <- models.LogEvent{
-							Timestamp: time.Now(),
-							Source:    "file",
-							Stream:    "stderr",
-							Message:   fmt.Sprintf("[shepai] File '%s' not found. Waiting for file...", f.filePath),
-						}
+						f.state.report(models.StatusFileNotFound, models.SeverityWarning,
+							fmt.Sprintf("File '%s' not found. Waiting for file...", f.filePath), nil)
 						fileWasDeleted = true
 						lastPos = 0 // Reset position when file is deleted
 					}
@@ -182,18 +287,17 @@ func (f *FileCollector) Start(ch chan<- models.LogEvent) error {
 
 				// File was found (or found again after deletion)
 				if fileWasDeleted {
-					ch <- models.LogEvent{
-						Timestamp: time.Now(),
-						Source:    "file",
-						Stream:    "stdout",
-						Message:   fmt.Sprintf("[shepai] File '%s' found. Resuming log streaming...", f.filePath),
-					}
+					f.state.report(models.StatusFileFound, models.SeverityInfo,
+						fmt.Sprintf("File '%s' found. Resuming log streaming...", f.filePath), nil)
 					fileWasDeleted = false
 					reconnectDelay = 2 * time.Second // Reset delay
+					f.opts.Metrics.Reconnect(f.filePath)
 				}
 
 				stat, err := file.Stat()
 				if err != nil {
+					lost = true
+					f.state.set(models.SourceReconnecting, err.Error())
 					file.Close()
 					time.Sleep(reconnectDelay)
 					if reconnectDelay < maxReconnectDelay {
@@ -202,23 +306,28 @@ func (f *FileCollector) Start(ch chan<- models.LogEvent) error {
 					continue
 				}
 
+				lost = false
+				f.state.set(models.SourceFollowing, "")
+
 				// Check for file rotation (size decreased)
 				if stat.Size() < lastPos {
-					ch <- models.LogEvent{
-						Timestamp: time.Now(),
-						Source:    "file",
-						Stream:    "stdout",
-						Message:   "[shepai] File rotation detected. Restarting from beginning...",
-					}
+					f.state.report(models.StatusFileRotated, models.SeverityInfo,
+						"File rotation detected. Restarting from beginning...",
+						map[string]string{"previousSize": fmt.Sprint(lastPos), "size": fmt.Sprint(stat.Size())})
 					lastPos = 0
 				}
 
 				if stat.Size() > lastPos {
+					format := f.textFormat(file)
+					if lastPos < format.bom {
+						lastPos = format.bom
+					}
+
 					file.Seek(lastPos, io.SeekStart)
-					scanner := bufio.NewScanner(file)
+					scanner := bufio.NewScanner(charset.NewReader(file, format.enc))
 
 					for scanner.Scan() {
-						line := scanner.Text()
+						line := charset.Sanitize(scanner.Text())
 						eventTime := time.Now()
 
 						event := models.LogEvent{
@@ -227,10 +336,14 @@ func (f *FileCollector) Start(ch chan<- models.LogEvent) error {
 							Stream:    "",
 							Message:   line,
 						}
+						f.opts.applyANSI(&event)
 
-						if parsedTime := f.parseTimestampFromLine(line); !parsedTime.IsZero() {
-							event.Timestamp = parsedTime
+						if m, ok := f.detector.Detect(event.Message); ok {
+							event.Timestamp = m.Time
+							event.Zone = zoneOf(m.Time)
 						}
+						f.opts.applyParsers(f.filePath, &event)
+						f.opts.applyCorrelation(&event)
 
 						select {
 						case ch <- event:
@@ -242,6 +355,11 @@ func (f *FileCollector) Start(ch chan<- models.LogEvent) error {
 
 					currentPos, _ := file.Seek(0, io.SeekCurrent)
 					lastPos = currentPos
+
+					// Never resume in the middle of a UTF-16 code unit
+					if !charset.LineOriented(format.enc) {
+						lastPos -= (lastPos - format.bom) % 2
+					}
 				}
 
 				file.Close()
@@ -259,56 +377,32 @@ func (f *FileCollector) Stop() error {
 	return nil
 }
 
+// NotifyStatus sends the file's status events to ch, such as the file
+// going missing or being rotated
+func (f *FileCollector) NotifyStatus(ch chan<- models.StatusEvent) {
+	f.state.setNotify(ch)
+}
+
+// SourceStatus reports whether the file is being followed or waited for
+func (f *FileCollector) SourceStatus() models.SourceStatus {
+	return f.state.get()
+}
+
 // GetSourceName returns the file path
 func (f *FileCollector) GetSourceName() string {
 	return f.filePath
 }
 
-// parseTimestampFromLine attempts to parse common timestamp formats from log lines
-func (f *FileCollector) parseTimestampFromLine(line string) time.Time {
-	formats := []string{
-		time.RFC3339,
-		time.RFC3339Nano,
-		"2006-01-02 15:04:05",
-		"2006-01-02T15:04:05",
-		"2006-01-02 15:04:05.000000",
-		"2006-01-02T15:04:05.000000",
-		"2006-01-02 15:04:05.000",
-		"2006-01-02T15:04:05.000",
-	}
+// textFormat determines how the file is decoded. A byte order mark takes
+// precedence over the configured encoding.
+func (f *FileCollector) textFormat(file *os.File) textFormat {
+	prefix := make([]byte, 4)
+	n, _ := file.ReadAt(prefix, 0)
 
-	// Try to find timestamp at the beginning of the line
-	for _, format := range formats {
-		if len(line) >= len(format) {
-			if t, err := time.Parse(format, line[:len(format)]); err == nil {
-				return t
-			}
-		}
+	if enc, size, ok := charset.DetectBOM(prefix[:n]); ok {
+		return textFormat{enc: enc, bom: int64(size)}
 	}
-
-	// Try Laravel-style: [2025-01-01 12:00:00]
-	if len(line) > 1 && line[0] == '[' {
-		end := 0
-		for i := 1; i < len(line); i++ {
-			if line[i] == ']' {
-				end = i
-				break
-			}
-		}
-		if end > 0 {
-			timestampStr := line[1:end]
-			for _, format := range []string{
-				"2006-01-02 15:04:05",
-				"2006-01-02T15:04:05",
-			} {
-				if t, err := time.Parse(format, timestampStr); err == nil {
-					return t
-				}
-			}
-		}
-	}
-
-	return time.Time{}
+	return textFormat{enc: f.encoding}
 }
 
 // parseLinesFromChunk extracts complete lines from a byte chunk
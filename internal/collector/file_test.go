@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestSnapshotUTF16(t *testing.T) {
+	// Long enough that the tail is found in a window of the file, with
+	// characters outside the BMP that a misaligned read would break
+	var text strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&text, "line %d 😀 ログ %s\r\n", i, strings.Repeat("x", i%50))
+	}
+	text.WriteString("last")
+
+	for _, tc := range []struct {
+		name     string
+		encoding string
+		endian   unicode.Endianness
+		bom      bool
+	}{
+		{"le with bom", "", unicode.LittleEndian, true},
+		{"be with bom", "", unicode.BigEndian, true},
+		{"le configured", "utf-16le", unicode.LittleEndian, false},
+		{"be configured", "utf-16be", unicode.BigEndian, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := unicode.IgnoreBOM
+			if tc.bom {
+				policy = unicode.UseBOM
+			}
+			data, err := unicode.UTF16(tc.endian, policy).NewEncoder().Bytes([]byte(text.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Half a character still being written
+			data = append(data, 0x3D)
+
+			path := filepath.Join(t.TempDir(), "app.log")
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := NewFileCollector(path, Options{Encoding: tc.encoding})
+			if err != nil {
+				t.Fatal(err)
+			}
+			events, err := c.GetSnapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(events) != DefaultSnapshotLines {
+				t.Fatalf("got %d events, want %d", len(events), DefaultSnapshotLines)
+			}
+			if got := events[len(events)-1].Message; got != "last" {
+				t.Errorf("last event = %q", got)
+			}
+			first := 5000 - DefaultSnapshotLines + 1
+			if want := fmt.Sprintf("line %d 😀 ログ %s", first, strings.Repeat("x", first%50)); events[0].Message != want {
+				t.Errorf("first event = %q, want %q", events[0].Message, want)
+			}
+		})
+	}
+}
+
+func TestSnapshotUTF16Short(t *testing.T) {
+	data, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte("one\r\n\r\ntwo\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewFileCollector(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := c.GetSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Message != "one" || events[1].Message != "two" {
+		t.Errorf("got %v", events)
+	}
+}
@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/ansi"
+	"github.com/monstarlab/shepai/internal/correlate"
+	"github.com/monstarlab/shepai/internal/metrics"
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/parser"
+	"github.com/monstarlab/shepai/internal/timestamp"
+)
+
+// Options configures how collectors turn raw lines into events
+type Options struct {
+	// Parsers are tried in order against every line; the first match wins
+	Parsers parser.Chain
+
+	// Location is used for timestamps written without a zone offset.
+	// Defaults to UTC.
+	Location *time.Location
+
+	// TimestampLayouts are extra Go time layouts to detect, tried before the
+	// built-in ones
+	TimestampLayouts []string
+
+	// ANSIMode is ansi.ModeSpans (default) or ansi.ModeStrip
+	ANSIMode string
+
+	// Encoding is the character encoding of file sources, e.g. "shift_jis".
+	// A byte order mark in the file takes precedence. Defaults to UTF-8.
+	Encoding string
+
+	// Metrics counts parse failures and reconnects; nil counts nothing
+	Metrics *metrics.Metrics
+}
+
+// applyANSI removes escape sequences from the message before it is parsed,
+// keeping the colors as style spans unless the mode is ansi.ModeStrip
+func (o Options) applyANSI(event *models.LogEvent) {
+	if !ansi.Contains(event.Message) {
+		return
+	}
+
+	if o.ANSIMode == ansi.ModeStrip {
+		event.Message = ansi.Strip(event.Message)
+		return
+	}
+	event.Message, event.Styles = ansi.Parse(event.Message)
+}
+
+// newDetector creates the timestamp detector for a single source.
+// Detectors learn the layout a source uses, so they are never shared.
+func (o Options) newDetector() (*timestamp.Detector, error) {
+	return timestamp.NewDetector(o.Location, o.TimestampLayouts)
+}
+
+// zoneOf describes the zone a parsed timestamp was written in, preferring the
+// IANA name (e.g. "Asia/Tokyo") and falling back to the numeric offset
+func zoneOf(t time.Time) string {
+	if name := t.Location().String(); name != "" && name != "Local" {
+		return name
+	}
+	return t.Format("Z07:00")
+}
+
+// applyParsers runs the configured parsers against the event message and
+// copies the extracted timestamp, level and fields onto the event. source
+// is the file or container it came from, counted on parse failures.
+func (o Options) applyParsers(source string, event *models.LogEvent) {
+	if len(o.Parsers) == 0 {
+		return
+	}
+
+	line := strings.TrimRight(event.Message, "\r\n")
+	res, _, ok := o.Parsers.Parse(line)
+	if !ok {
+		o.Metrics.ParseFailure(source)
+		return
+	}
+
+	if !res.Timestamp.IsZero() {
+		event.Timestamp = res.Timestamp
+		event.Zone = zoneOf(res.Timestamp)
+	}
+	event.Level = res.Level
+
+	if len(res.Fields) > 0 || res.Message != "" {
+		event.Fields = make(map[string]string, len(res.Fields)+1)
+		for k, v := range res.Fields {
+			event.Fields[k] = v
+		}
+		if res.Message != "" {
+			event.Fields[parser.GroupMessage] = res.Message
+		}
+	}
+}
+
+// applyCorrelation records the trace and request IDs found in the event, so
+// related events can be looked up across sources
+func (o Options) applyCorrelation(event *models.LogEvent) {
+	event.Correlation = correlate.Extract(event.Message, event.Fields)
+}
@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// sourceState is the status a collector reports for its source, and where
+// it sends its status events
+type sourceState struct {
+	mu     sync.Mutex
+	status models.SourceStatus
+
+	notify   chan<- models.StatusEvent // nil sends none
+	lastCode string                    // code of the last status event
+}
+
+func newSourceState(name, kind string) *sourceState {
+	return &sourceState{status: models.SourceStatus{
+		Name:  name,
+		Kind:  kind,
+		State: models.SourceStarting,
+		Since: time.Now(),
+	}}
+}
+
+// set records the source's state. Following resets the attempts.
+func (s *sourceState) set(state, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state != s.status.State {
+		s.status.Since = time.Now()
+	}
+	s.status.State = state
+	s.status.Detail = detail
+	if state == models.SourceFollowing {
+		s.status.Attempt = 0
+	}
+}
+
+// retry counts an attempt to reopen or reconnect to the source. Only the
+// attempt itself counts, not each check of the source while waiting.
+func (s *sourceState) retry() {
+	s.mu.Lock()
+	s.status.Attempt++
+	s.mu.Unlock()
+}
+
+func (s *sourceState) get() models.SourceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *sourceState) setNotify(ch chan<- models.StatusEvent) {
+	s.mu.Lock()
+	s.notify = ch
+	s.mu.Unlock()
+}
+
+// report sends a status event about the source. Repeats of the last event
+// sent, e.g. on every retry while a container is missing, aren't sent
+// again; rotations always are. An event dropped because the channel is full
+// isn't the last sent, so a repeat of it is sent.
+func (s *sourceState) report(code, severity, message string, details map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.notify == nil || (code == s.lastCode && code != models.StatusFileRotated) {
+		return
+	}
+
+	select {
+	case s.notify <- models.StatusEvent{
+		Time:     time.Now(),
+		Source:   s.status.Name,
+		Kind:     s.status.Kind,
+		Code:     code,
+		Severity: severity,
+		Message:  message,
+		Details:  details,
+	}:
+		s.lastCode = code
+	default:
+	}
+}
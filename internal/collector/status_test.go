@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+func TestSourceStateAttempts(t *testing.T) {
+	// Each step sets a state, after an attempt to reopen or reconnect when
+	// retry is true
+	type step struct {
+		retry bool
+		state string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		want  int
+	}{
+		{"following", []step{{false, models.SourceFollowing}}, 0},
+		{"waiting without retrying", []step{{false, models.SourceWaiting}, {false, models.SourceWaiting}, {false, models.SourceStopped}}, 0},
+		{"lost and reported twice", []step{{false, models.SourceFollowing}, {false, models.SourceReconnecting}, {false, models.SourceWaiting}}, 0},
+		{"retries", []step{{false, models.SourceReconnecting}, {true, models.SourceWaiting}, {true, models.SourceWaiting}, {true, models.SourceReconnecting}}, 3},
+		{"followed again", []step{{false, models.SourceWaiting}, {true, models.SourceWaiting}, {true, models.SourceFollowing}}, 0},
+		{"lost again", []step{{true, models.SourceWaiting}, {true, models.SourceFollowing}, {false, models.SourceReconnecting}, {true, models.SourceReconnecting}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSourceState("app.log", "file")
+			for _, st := range tt.steps {
+				if st.retry {
+					s.retry()
+				}
+				s.set(st.state, "")
+			}
+			if got := s.get().Attempt; got != tt.want {
+				t.Errorf("attempt %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceStateReport(t *testing.T) {
+	// Each step reports a code; full fills the channel first
+	type step struct {
+		code string
+		full bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		want  []string
+	}{
+		{"repeats", []step{{models.StatusContainerNotFound, false}, {models.StatusContainerNotFound, false}}, []string{models.StatusContainerNotFound}},
+		{"changes", []step{{models.StatusFileNotFound, false}, {models.StatusFileFound, false}, {models.StatusFileNotFound, false}}, []string{models.StatusFileNotFound, models.StatusFileFound, models.StatusFileNotFound}},
+		{"rotations", []step{{models.StatusFileRotated, false}, {models.StatusFileRotated, false}}, []string{models.StatusFileRotated, models.StatusFileRotated}},
+		{"dropped then repeated", []step{{models.StatusConnectionLost, true}, {models.StatusConnectionLost, false}}, []string{models.StatusConnectionLost}},
+		{"dropped change then repeated", []step{{models.StatusFileNotFound, false}, {models.StatusFileFound, true}, {models.StatusFileFound, false}}, []string{models.StatusFileNotFound, models.StatusFileFound}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan models.StatusEvent, 1)
+			s := newSourceState("app.log", "file")
+			s.setNotify(ch)
+
+			var got []string
+			for _, st := range tt.steps {
+				if st.full {
+					ch <- models.StatusEvent{Code: "filler"}
+				}
+				s.report(st.code, models.SeverityInfo, "", nil)
+				for len(ch) > 0 {
+					if event := <-ch; event.Code != "filler" {
+						got = append(got, event.Code)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
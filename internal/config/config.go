@@ -0,0 +1,309 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/ansi"
+	"github.com/monstarlab/shepai/internal/charset"
+	"github.com/monstarlab/shepai/internal/timestamp"
+)
+
+// DefaultPath is the config file picked up from the working directory when
+// no --config flag is given
+const DefaultPath = "shepai.json"
+
+// Config holds user-defined settings loaded from a JSON config file
+type Config struct {
+	// Parsers are applied in order to every log line; the first match wins
+	Parsers []ParserRule `json:"parsers"`
+
+	// GrokPatterns lists files with custom grok pattern definitions
+	GrokPatterns []string `json:"grokPatterns"`
+
+	// Timezone is the IANA zone used for timestamps written without an offset
+	Timezone string `json:"timezone"`
+
+	// TimestampLayouts are extra Go time layouts for timestamp detection
+	TimestampLayouts []string `json:"timestampLayouts"`
+
+	// Sources holds per-source overrides, keyed by file path or container name
+	Sources map[string]SourceConfig `json:"sources"`
+
+	Multiline MultilineConfig `json:"multiline"`
+
+	// ANSI is "spans" (keep colors for the dashboard) or "strip"
+	ANSI string `json:"ansi"`
+
+	// Encoding is the character encoding of file sources, e.g. "shift_jis"
+	Encoding string `json:"encoding"`
+
+	Redact RedactConfig `json:"redact"`
+
+	Store StoreConfig `json:"store"`
+
+	WebSocket WebSocketConfig `json:"websocket"`
+}
+
+// WebSocketConfig controls how events are sent to dashboard clients
+type WebSocketConfig struct {
+	// QueueSize is how many events may wait to be sent to a slow client
+	// (default: 1024)
+	QueueSize int `json:"queueSize"`
+
+	// Overflow is what happens when a client's queue is full: "drop" drops
+	// the oldest events and marks the gap (default), "disconnect" closes
+	// the connection so the client reconnects and resumes
+	Overflow string `json:"overflow"`
+}
+
+// StoreConfig controls the on-disk event store
+type StoreConfig struct {
+	// Dir enables the store; each source gets a subdirectory
+	Dir string `json:"dir"`
+
+	// MaxSize caps the store of each source, e.g. "500MB" (default: 1GB)
+	MaxSize ByteSize `json:"maxSize"`
+
+	// MaxAge drops history older than this, e.g. "72h" (default: no limit)
+	MaxAge Duration `json:"maxAge"`
+}
+
+// RedactConfig controls how secrets and personal data are hidden
+type RedactConfig struct {
+	Disabled bool `json:"disabled"`
+
+	// Disable turns off built-in rules: jwt, bearer, aws-key, aws-secret,
+	// dsn, password, email, pan
+	Disable []string `json:"disable"`
+
+	// Rules are extra patterns to hide
+	Rules []RedactRule `json:"rules"`
+}
+
+// RedactRule is a user-defined redaction pattern. A named group "secret"
+// limits what is hidden; otherwise the whole match is.
+type RedactRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// MultilineConfig controls how stack traces are grouped into a single entry
+type MultilineConfig struct {
+	Disabled bool `json:"disabled"`
+
+	// Rules limits grouping to these languages: php, java, python, go, node
+	Rules []string `json:"rules"`
+
+	// FlushTimeout is how long to wait for more lines of the last entry, e.g. "500ms"
+	FlushTimeout Duration `json:"flushTimeout"`
+
+	// MaxLines caps the continuation lines attached to one entry
+	MaxLines int `json:"maxLines"`
+}
+
+// Duration is a time.Duration written as a string such as "500ms" or "2s"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"500ms\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ByteSize is a size in bytes, written as a number or a string such as
+// "500MB" or "2GB"
+type ByteSize int64
+
+// byteUnits are the accepted size suffixes, longest first
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40}, {"B", 1},
+}
+
+// UnmarshalJSON parses a size
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("size must be a number or a string such as \"500MB\": %w", err)
+	}
+
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range byteUnits {
+		if number, ok := strings.CutSuffix(s, unit.suffix); ok {
+			value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil || value < 0 {
+				return fmt.Errorf("invalid size %q", s)
+			}
+			*b = ByteSize(value * float64(unit.size))
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid size %q (use e.g. \"500MB\")", s)
+}
+
+// SourceConfig holds settings that apply to a single source
+type SourceConfig struct {
+	Timezone string `json:"timezone"`
+	Encoding string `json:"encoding"`
+}
+
+// Parser types
+const (
+	ParserTypeRegex = "regex"
+	ParserTypeGrok  = "grok"
+)
+
+// ParserRule describes a single user-defined parser.
+// For "regex" rules, Pattern is a Go regular expression using named capture
+// groups. For "grok" rules, it is a grok expression such as
+// %{TIMESTAMP_ISO8601:ts} %{LOGLEVEL:level} %{GREEDYDATA:msg}.
+// Either way, "ts", "level" and "msg" are recognised and any other named
+// group is kept as an extra field.
+type ParserRule struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"` // "regex" (default) or "grok"
+	Pattern    string `json:"pattern"`
+	TimeLayout string `json:"timeLayout"` // Go time layout for the "ts" group
+}
+
+// Load reads and validates the config file at path
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i, rule := range cfg.Parsers {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("parser #%d in %s has no name", i+1, path)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("duplicate parser name %q in %s", rule.Name, path)
+		}
+		seen[rule.Name] = true
+
+		switch rule.Type {
+		case "":
+			cfg.Parsers[i].Type = ParserTypeRegex
+		case ParserTypeRegex, ParserTypeGrok:
+		default:
+			return nil, fmt.Errorf("parser %q in %s has unknown type %q", rule.Name, path, rule.Type)
+		}
+	}
+
+	if cfg.ANSI != "" && !ansi.ValidMode(cfg.ANSI) {
+		return nil, fmt.Errorf("invalid ansi mode %q in %s (expected %q or %q)", cfg.ANSI, path, ansi.ModeSpans, ansi.ModeStrip)
+	}
+
+	for _, layout := range cfg.TimestampLayouts {
+		if _, err := timestamp.NewLayout(layout); err != nil {
+			return nil, fmt.Errorf("%w in %s", err, path)
+		}
+	}
+
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone in %s: %w", path, err)
+		}
+	}
+	switch cfg.WebSocket.Overflow {
+	case "", "drop", "disconnect":
+	default:
+		return nil, fmt.Errorf("invalid websocket overflow %q in %s (expected \"drop\" or \"disconnect\")", cfg.WebSocket.Overflow, path)
+	}
+	if cfg.WebSocket.QueueSize < 0 {
+		return nil, fmt.Errorf("invalid websocket queueSize %d in %s", cfg.WebSocket.QueueSize, path)
+	}
+
+	for i, rule := range cfg.Redact.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("redaction rule %d in %s has no name", i+1, path)
+		}
+	}
+
+	if _, err := charset.Lookup(cfg.Encoding); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, path)
+	}
+	for source, sourceCfg := range cfg.Sources {
+		if sourceCfg.Timezone != "" {
+			if _, err := time.LoadLocation(sourceCfg.Timezone); err != nil {
+				return nil, fmt.Errorf("invalid timezone for source %q in %s: %w", source, path, err)
+			}
+		}
+		if _, err := charset.Lookup(sourceCfg.Encoding); err != nil {
+			return nil, fmt.Errorf("%w for source %q in %s", err, source, path)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// LoadOrDefault loads the config at path. If path is empty, it falls back to
+// DefaultPath when that file exists, and to an empty config otherwise.
+func LoadOrDefault(path string) (*Config, error) {
+	if path != "" {
+		return Load(path)
+	}
+
+	if _, err := os.Stat(DefaultPath); err == nil {
+		return Load(DefaultPath)
+	}
+
+	return &Config{}, nil
+}
+
+// TimezoneFor returns the timezone configured for a source, falling back to
+// the top-level timezone
+func (c *Config) TimezoneFor(source string) string {
+	if sourceCfg, ok := c.Sources[source]; ok && sourceCfg.Timezone != "" {
+		return sourceCfg.Timezone
+	}
+	return c.Timezone
+}
+
+// EncodingFor returns the character encoding configured for a source, falling
+// back to the top-level encoding
+func (c *Config) EncodingFor(source string) string {
+	if sourceCfg, ok := c.Sources[source]; ok && sourceCfg.Encoding != "" {
+		return sourceCfg.Encoding
+	}
+	return c.Encoding
+}
+
+// Parser returns the parser rule with the given name
+func (c *Config) Parser(name string) (ParserRule, bool) {
+	for _, rule := range c.Parsers {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return ParserRule{}, false
+}
@@ -0,0 +1,73 @@
+package correlate
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of correlation IDs
+const (
+	KindTrace   = "trace"
+	KindRequest = "request"
+)
+
+// extractor finds one kind of ID in a log line
+type extractor struct {
+	kind string
+	re   *regexp.Regexp // the ID is the first capture group
+}
+
+// extractors are tried in order; the first match of each kind wins
+var extractors = []extractor{
+	// W3C traceparent: version-traceid-parentid-flags
+	{KindTrace, regexp.MustCompile(`\b[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}\b`)},
+	// trace_id=..., "traceId": "...", trace.id: ...
+	{KindTrace, regexp.MustCompile(`(?i)\btrace[_.-]?id["']?\s*[:=]\s*["']?([0-9A-Za-z-]{8,64})`)},
+	// X-Request-Id: ..., request_id=..., "requestId": "..." (e.g. Laravel's log context)
+	{KindRequest, regexp.MustCompile(`(?i)\b(?:x-)?request[_.-]?id["']?\s*[:=]\s*["']?([0-9A-Za-z._:-]{6,128})`)},
+}
+
+// fieldKinds maps parser field names to the kind of ID they hold
+var fieldKinds = map[string]string{
+	"trace_id":     KindTrace,
+	"traceid":      KindTrace,
+	"trace.id":     KindTrace,
+	"request_id":   KindRequest,
+	"requestid":    KindRequest,
+	"x-request-id": KindRequest,
+}
+
+// Extract returns the correlation IDs of a log line keyed by kind, or nil if
+// there are none. Fields extracted by a parser take precedence over IDs
+// found in the message text.
+func Extract(message string, fields map[string]string) map[string]string {
+	var ids map[string]string
+	set := func(kind, id string) {
+		if id == "" {
+			return
+		}
+		if ids == nil {
+			ids = make(map[string]string)
+		}
+		if _, ok := ids[kind]; !ok {
+			ids[kind] = id
+		}
+	}
+
+	for name, value := range fields {
+		if kind, ok := fieldKinds[strings.ToLower(name)]; ok {
+			set(kind, value)
+		}
+	}
+
+	for _, e := range extractors {
+		if _, ok := ids[e.kind]; ok {
+			continue
+		}
+		if m := e.re.FindStringSubmatch(message); m != nil {
+			set(e.kind, m[1])
+		}
+	}
+
+	return ids
+}
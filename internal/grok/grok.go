@@ -0,0 +1,177 @@
+package grok
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds pattern expansion so recursive definitions fail fast
+const maxDepth = 64
+
+// referenceRe matches %{NAME}, %{NAME:field} and %{NAME:field:type}
+var referenceRe = regexp.MustCompile(`%\{(\w+)(?::([^:}]+))?(?::\w+)?\}`)
+
+// Library holds named grok patterns
+type Library struct {
+	patterns map[string]string
+}
+
+// Expression is a compiled grok expression
+type Expression struct {
+	Regexp *regexp.Regexp
+
+	// Fields maps each capture group index to the grok field name it was
+	// declared with. Grok field names may contain characters Go doesn't allow
+	// in group names (e.g. "http.status"), so groups are numbered internally,
+	// behind a prefix no group written in the expression starts with.
+	Fields []string
+}
+
+// NewLibrary returns a library preloaded with the base patterns
+func NewLibrary() *Library {
+	patterns := make(map[string]string, len(basePatterns))
+	for name, pattern := range basePatterns {
+		patterns[name] = pattern
+	}
+	return &Library{patterns: patterns}
+}
+
+// Add registers a pattern, replacing any existing pattern with the same name
+func (l *Library) Add(name, pattern string) {
+	l.patterns[name] = pattern
+}
+
+// LoadFile reads custom patterns in the Logstash patterns file format:
+// one "NAME pattern" definition per line, with # comments and blank lines ignored
+func (l *Library) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open grok patterns: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// The name ends at the first run of spaces or tabs, which pattern
+		// files often use to line up the patterns
+		i := strings.IndexAny(line, " \t")
+		if i < 0 || strings.TrimSpace(line[i:]) == "" {
+			return fmt.Errorf("%s:%d: expected \"NAME pattern\"", path, lineNo)
+		}
+		l.Add(line[:i], strings.TrimSpace(line[i:]))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read grok patterns: %w", err)
+	}
+	return nil
+}
+
+// Compile expands all pattern references in expr and compiles the result
+func (l *Library) Compile(expr string) (*Expression, error) {
+	// Expanded without field groups first, to find a prefix for their names
+	// that appears nowhere else, so (?P<name>...) groups written in the
+	// expression or in patterns can't be taken for fields
+	plain, err := l.expand(expr, "", nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	prefix := "g"
+	for strings.Contains(plain, prefix) {
+		prefix = "_" + prefix
+	}
+
+	var fields []string
+	expanded, err := l.expand(expr, prefix, &fields, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grok expression: %w", err)
+	}
+
+	// Index 0 is the whole match
+	groupFields := make([]string, re.NumSubexp()+1)
+	for i, name := range re.SubexpNames() {
+		if idx, ok := fieldIndex(name, prefix); ok && idx < len(fields) {
+			groupFields[i] = fields[idx]
+		} else {
+			// Plain (?P<name>...) groups written directly in the expression
+			groupFields[i] = name
+		}
+	}
+
+	return &Expression{Regexp: re, Fields: groupFields}, nil
+}
+
+// fieldIndex returns the number of a field group name: the prefix followed
+// by digits and nothing else
+func fieldIndex(name, prefix string) (int, bool) {
+	digits, ok := strings.CutPrefix(name, prefix)
+	if !ok || digits == "" {
+		return 0, false
+	}
+	idx, err := strconv.Atoi(digits)
+	if err != nil || strconv.Itoa(idx) != digits {
+		return 0, false
+	}
+	return idx, true
+}
+
+// expand recursively replaces %{NAME:field} references with their patterns.
+// Named references become numbered capture groups (g0, g1, ... behind the
+// prefix) and the field name is recorded in fields at that number; with no
+// prefix they're left uncaptured.
+func (l *Library) expand(expr, prefix string, fields *[]string, depth int) (string, error) {
+	if depth > maxDepth {
+		return "", fmt.Errorf("grok pattern nesting too deep (recursive definition?)")
+	}
+
+	var expandErr error
+	result := referenceRe.ReplaceAllStringFunc(expr, func(ref string) string {
+		if expandErr != nil {
+			return ""
+		}
+
+		parts := referenceRe.FindStringSubmatch(ref)
+		name, field := parts[1], parts[2]
+
+		pattern, ok := l.patterns[name]
+		if !ok {
+			expandErr = fmt.Errorf("unknown grok pattern %%{%s}", name)
+			return ""
+		}
+
+		inner, err := l.expand(pattern, prefix, fields, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+
+		if field == "" || prefix == "" {
+			return "(?:" + inner + ")"
+		}
+
+		group := prefix + strconv.Itoa(len(*fields))
+		*fields = append(*fields, field)
+		return "(?P<" + group + ">" + inner + ")"
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package grok
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patterns")
+	content := strings.Join([]string{
+		"# Custom patterns",
+		"",
+		"ORDERID ORD-%{INT}",
+		"TICKET\t[A-Z]+-\\d+",
+		"QUEUE    \t [a-z]+ ",
+		"  INDENTED  x+",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lib := NewLibrary()
+	if err := lib.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"ORDERID":  "ORD-%{INT}",
+		"TICKET":   `[A-Z]+-\d+`,
+		"QUEUE":    "[a-z]+",
+		"INDENTED": "x+",
+	} {
+		if got := lib.patterns[name]; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	expr, err := lib.Compile("%{ORDERID:order} on %{QUEUE:queue}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := expr.Regexp.FindStringSubmatch("ORD-42 on emails")
+	if match == nil {
+		t.Fatal("expression didn't match")
+	}
+	got := map[string]string{}
+	for i, field := range expr.Fields {
+		if field != "" {
+			got[field] = match[i]
+		}
+	}
+	if got["order"] != "ORD-42" || got["queue"] != "emails" {
+		t.Errorf("fields = %v", got)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, content, want string
+	}{
+		{"name only", "ORDERID\n", ":1:"},
+		{"name and blanks", "# comment\nORDERID \t \n", ":2:"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "patterns")
+			if err := os.WriteFile(path, []byte(tc.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := NewLibrary().LoadFile(path)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("LoadFile = %v, want an error at %s", err, tc.want)
+			}
+		})
+	}
+
+	if err := NewLibrary().LoadFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing file was loaded")
+	}
+}
+
+func TestCompile(t *testing.T) {
+	lib := NewLibrary()
+	lib.Add("NAMED", "(?P<g1>\\w+)")
+	for _, tc := range []struct {
+		expr, line string
+		want       map[string]string
+	}{
+		{"%{IP:client} %{WORD:method} %{URIPATHPARAM:path}", "10.0.0.1 GET /index.html?a=1",
+			map[string]string{"client": "10.0.0.1", "method": "GET", "path": "/index.html?a=1"}},
+		{"%{LOGLEVEL:level}: %{GREEDYDATA:msg}", "ERROR: boom",
+			map[string]string{"level": "ERROR", "msg": "boom"}},
+		// Dotted field names, which Go doesn't allow as group names
+		{"status=%{NUMBER:http.status:int}", "status=404",
+			map[string]string{"http.status": "404"}},
+		// Plain named groups next to grok references
+		{"(?P<user>\\w+) %{INT:uid}", "ana 1000",
+			map[string]string{"user": "ana", "uid": "1000"}},
+		// Plain groups named like the internal ones stay theirs
+		{"(?P<g0>\\w+) %{INT:uid}", "ana 1000",
+			map[string]string{"g0": "ana", "uid": "1000"}},
+		{"(?P<g0x>\\w+) %{INT:uid} %{WORD:shell}", "ana 1000 zsh",
+			map[string]string{"g0x": "ana", "uid": "1000", "shell": "zsh"}},
+		{"%{INT:uid} (?P<_g1>\\w+) %{WORD:shell}", "1000 ana zsh",
+			map[string]string{"_g1": "ana", "uid": "1000", "shell": "zsh"}},
+		{"%{NAMED:name} %{INT:uid}", "ana 1000",
+			map[string]string{"name": "ana", "g1": "ana", "uid": "1000"}},
+	} {
+		expr, err := lib.Compile(tc.expr)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tc.expr, err)
+			continue
+		}
+		match := expr.Regexp.FindStringSubmatch(tc.line)
+		if match == nil {
+			t.Errorf("%q didn't match %q", tc.expr, tc.line)
+			continue
+		}
+		for field, want := range tc.want {
+			got := ""
+			for i, f := range expr.Fields {
+				if f == field {
+					got = match[i]
+				}
+			}
+			if got != want {
+				t.Errorf("%q: %s = %q, want %q", tc.expr, field, got, want)
+			}
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	lib := NewLibrary()
+	lib.Add("LOOP", "a%{LOOP}")
+	for _, expr := range []string{"%{NOPE:x}", "%{LOOP}", "(%{WORD}"} {
+		if _, err := lib.Compile(expr); err == nil {
+			t.Errorf("Compile(%q) succeeded", expr)
+		}
+	}
+}
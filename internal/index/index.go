@@ -0,0 +1,370 @@
+// Package index maintains an inverted index over log events so searches
+// don't have to scan every event.
+package index
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+// minPartialWord is the shortest partial word worth expanding against the
+// vocabulary; shorter ones match nearly every event
+const minPartialWord = 2
+
+// DefaultChunkSize is how many positions a chunk of the index covers by
+// default
+const DefaultChunkSize = 1 << 16
+
+// Index maps the lowercased words of event messages and details, and the
+// query terms of events, to the ascending positions of the events having
+// them. It implements query.Index.
+type Index struct {
+	mu sync.RWMutex
+
+	// Chunks are dropped whole as soon as all their events expire, so the
+	// index holds at most chunkSize events more than those retained
+	chunks    []*chunk // oldest first
+	chunkSize int64
+
+	// first is the oldest position still indexed; the oldest chunk may hold
+	// older ones
+	first int64
+}
+
+// chunk indexes the events with positions in [start, start+chunkSize)
+type chunk struct {
+	start int64
+	words map[string][]int64
+	terms map[string][]int64
+
+	// grams maps the trigrams of the words, padded with ^ and $ at the
+	// ends, to the words having them, so partial words are looked up without
+	// scanning the vocabulary
+	grams map[string][]string
+}
+
+// New creates an empty index whose chunks cover chunkSize positions, or
+// DefaultChunkSize if it's zero
+func New(chunkSize int64) *Index {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &Index{chunkSize: chunkSize}
+}
+
+// Add indexes the event at pos. Events must be added in position order.
+func (x *Index) Add(pos int64, event models.LogEvent) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	c := x.chunkFor(pos)
+	for _, word := range eventWords(event) {
+		list, ok := c.words[word]
+		if !ok {
+			for _, gram := range wordGrams(word) {
+				c.grams[gram] = append(c.grams[gram], word)
+			}
+		}
+		c.words[word] = addPosition(list, pos)
+	}
+	for _, term := range query.Terms(event) {
+		c.terms[term] = addPosition(c.terms[term], pos)
+	}
+}
+
+// chunkFor returns the chunk pos belongs in, starting one if needed
+func (x *Index) chunkFor(pos int64) *chunk {
+	if n := len(x.chunks); n > 0 && pos < x.chunks[n-1].start+x.chunkSize {
+		return x.chunks[n-1]
+	}
+	c := &chunk{
+		start: pos - pos%x.chunkSize,
+		words: make(map[string][]int64),
+		terms: make(map[string][]int64),
+		grams: make(map[string][]string),
+	}
+	x.chunks = append(x.chunks, c)
+	return c
+}
+
+// addPosition appends pos unless the event was already added to the list
+func addPosition(list []int64, pos int64) []int64 {
+	if len(list) > 0 && list[len(list)-1] == pos {
+		return list
+	}
+	return append(list, pos)
+}
+
+// Expire drops the events before first. Chunks whose events have all
+// expired are dropped at once; the rest of the oldest chunk is skipped by
+// lookups until it expires too.
+func (x *Index) Expire(first int64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if first <= x.first {
+		return
+	}
+	x.first = first
+
+	i := 0
+	for i < len(x.chunks) && x.chunks[i].start+x.chunkSize <= first {
+		i++
+	}
+	if i > 0 {
+		x.chunks = append([]*chunk(nil), x.chunks[i:]...)
+	}
+}
+
+// live returns the part of a list that hasn't expired
+func (x *Index) live(list []int64) []int64 {
+	i := sort.Search(len(list), func(i int) bool { return list[i] >= x.first })
+	return list[i:]
+}
+
+// Term returns the events having a query term
+func (x *Index) Term(term string) []int64 {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var result []int64
+	for _, c := range x.chunks {
+		result = append(result, x.live(c.terms[term])...)
+	}
+	return result
+}
+
+// Text returns the events that may contain text. Every word of text must be
+// a word of the event; the first and last may be partial, so they are
+// matched against the vocabulary. A single word of text is matched exactly.
+func (x *Index) Text(text string) ([]int64, bool, bool) {
+	lower := strings.ToLower(text)
+	words := splitWords(lower)
+	if len(words) == 0 {
+		return nil, false, false
+	}
+	exact := len(words) == 1 && words[0].text == lower
+
+	// Words too short to narrow the search are skipped
+	var lookups []word
+	for _, w := range words {
+		if isWhole(w, lower) || len(w.text) >= minPartialWord {
+			lookups = append(lookups, w)
+		}
+	}
+	if len(lookups) == 0 {
+		return nil, false, false
+	}
+	exact = exact && len(lookups) == len(words)
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	// Chunks cover consecutive ranges, so their results are in order
+	var result []int64
+	for _, c := range x.chunks {
+		var found []int64
+		for i, w := range lookups {
+			list := c.lookup(w, lower)
+			if i == 0 {
+				found = list
+			} else {
+				found = intersectPositions(found, list)
+			}
+			if len(found) == 0 {
+				break
+			}
+		}
+		result = append(result, x.live(found)...)
+	}
+
+	return result, exact, true
+}
+
+// isWhole reports whether w is a whole word of the event wherever text
+// matches: text has a separator on both sides of it
+func isWhole(w word, text string) bool {
+	return w.start > 0 && w.start+len(w.text) < len(text)
+}
+
+// lookup returns the events of the chunk with a word matching w, a word of
+// text: the same word if it's whole, or else one containing it, starting
+// with it if text continues before it and ending with it if text continues
+// after it
+func (c *chunk) lookup(w word, text string) []int64 {
+	startsWord := w.start > 0
+	endsWord := w.start+len(w.text) < len(text)
+	if startsWord && endsWord {
+		return c.words[w.text]
+	}
+
+	part := w.text
+	match := strings.Contains
+	switch {
+	case startsWord:
+		part, match = "^"+part, strings.HasPrefix
+	case endsWord:
+		part, match = part+"$", strings.HasSuffix
+	}
+
+	// Every word containing part has all its trigrams, so the words of the
+	// rarest one are the only candidates
+	var candidates []string
+	if grams := trigrams(part); len(grams) > 0 {
+		for i, gram := range grams {
+			list, ok := c.grams[gram]
+			if !ok {
+				return nil
+			}
+			if i == 0 || len(list) < len(candidates) {
+				candidates = list
+			}
+		}
+	} else {
+		// Too short for a trigram: any word with a trigram containing it
+		seen := make(map[string]bool)
+		for gram, list := range c.grams {
+			if !strings.Contains(gram, part) {
+				continue
+			}
+			for _, word := range list {
+				if !seen[word] {
+					seen[word] = true
+					candidates = append(candidates, word)
+				}
+			}
+		}
+	}
+
+	var lists [][]int64
+	for _, word := range candidates {
+		if match(word, w.text) {
+			lists = append(lists, c.words[word])
+		}
+	}
+	return mergePositions(lists)
+}
+
+// wordGrams returns the trigrams a word of the vocabulary is indexed by: those
+// of the word padded with ^ and $, so words of two characters have one too
+func wordGrams(word string) []string {
+	return trigrams("^" + word + "$")
+}
+
+// trigrams returns the distinct three-character substrings of s
+func trigrams(s string) []string {
+	runes := []rune(s)
+	var grams []string
+	seen := make(map[string]bool)
+	for i := 0; i+3 <= len(runes); i++ {
+		gram := string(runes[i : i+3])
+		if !seen[gram] {
+			seen[gram] = true
+			grams = append(grams, gram)
+		}
+	}
+	return grams
+}
+
+// mergePositions returns the distinct positions of ascending lists, ascending
+func mergePositions(lists [][]int64) []int64 {
+	switch len(lists) {
+	case 0:
+		return nil
+	case 1:
+		return lists[0]
+	}
+
+	var total int
+	for _, list := range lists {
+		total += len(list)
+	}
+	merged := make([]int64, 0, total)
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i] < merged[j] })
+
+	deduped := merged[:0]
+	for i, pos := range merged {
+		if i == 0 || pos != merged[i-1] {
+			deduped = append(deduped, pos)
+		}
+	}
+	return deduped
+}
+
+func intersectPositions(a, b []int64) []int64 {
+	var result []int64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// word is a run of letters, digits and underscores in a text
+type word struct {
+	text  string
+	start int
+}
+
+// splitWords returns the words of s
+func splitWords(s string) []word {
+	var words []word
+	start := -1
+	for i, r := range s {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, word{text: s[start:i], start: start})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, word{text: s[start:], start: start})
+	}
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// eventWords returns the distinct lowercased words of an event's message
+// and details
+func eventWords(event models.LogEvent) []string {
+	seen := make(map[string]bool)
+	var words []string
+	add := func(s string) {
+		for _, w := range splitWords(strings.ToLower(s)) {
+			if !seen[w.text] {
+				seen[w.text] = true
+				words = append(words, w.text)
+			}
+		}
+	}
+
+	add(event.Message)
+	for _, detail := range event.Details {
+		add(detail)
+	}
+	return words
+}
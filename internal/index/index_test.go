@@ -0,0 +1,183 @@
+package index
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+func newIndex(chunkSize int64, messages ...string) *Index {
+	x := New(chunkSize)
+	for i, message := range messages {
+		x.Add(int64(i), models.LogEvent{Message: message})
+	}
+	return x
+}
+
+func TestText(t *testing.T) {
+	x := newIndex(4,
+		"Connection timeout after 3000ms", // 0
+		"readTimeout exceeded",            // 1
+		"GET /health OK",                  // 2
+		"token refreshed",                 // 3
+		"payment failed",                  // 4
+		"日本語のログ 出力",                       // 5
+		"timeout_ms=50",                   // 6
+	)
+	x.Add(7, models.LogEvent{Message: "boom", Details: []string{"\tat Pay.charge(Pay.java:12)"}})
+
+	for _, tc := range []struct {
+		text      string
+		want      []int64
+		exact, ok bool
+	}{
+		// A single word is found inside words, and every match contains it
+		{"timeout", []int64{0, 1, 6}, true, true},
+		{"TIME", []int64{0, 1, 6}, true, true},
+		{"ok", []int64{2, 3}, true, true}, // too short for a trigram
+		{"ログ", []int64{5}, true, true},
+		{"nothing", nil, true, true},
+
+		// Inner words are whole, outer ones partial
+		{"connection timeout", []int64{0}, false, true},
+		{"eout after", []int64{0}, false, true},
+		{"ion timeout aft", []int64{0}, false, true},
+		{"a timeout", []int64{0, 6}, false, true}, // "timeout" starts a word
+		{"timeout_ms=", []int64{6}, false, true},
+		{"pay.java", []int64{7}, false, true},
+		{"charge(pay", []int64{7}, false, true},
+		{"health ok", []int64{2}, false, true},
+		{"get health", []int64{2}, false, true}, // candidates: the words aren't adjacent
+		{"payment timeout", nil, false, true},
+
+		// Nothing to narrow the search with
+		{"x", nil, false, false},
+		{" - ", nil, false, false},
+	} {
+		got, exact, ok := x.Text(tc.text)
+		if !reflect.DeepEqual(got, tc.want) || exact != tc.exact || ok != tc.ok {
+			t.Errorf("Text(%q) = %v %v %v, want %v %v %v", tc.text, got, exact, ok, tc.want, tc.exact, tc.ok)
+		}
+	}
+}
+
+func TestTerm(t *testing.T) {
+	x := New(2)
+	x.Add(0, models.LogEvent{Source: "docker", Stream: "stderr", Message: "ERROR boom",
+		Correlation: map[string]string{"trace": "abc"}})
+	x.Add(1, models.LogEvent{Source: "file", Message: "ok"})
+	x.Add(2, models.LogEvent{Source: "docker", Stream: "stdout", Level: "err", Message: "x",
+		Correlation: map[string]string{"request": "abc"}})
+
+	for _, tc := range []struct {
+		term string
+		want []int64
+	}{
+		{"level:error", []int64{0, 2}},
+		{"level:success", []int64{1}},
+		{"source:docker", []int64{0, 2}},
+		{"stream:stderr", []int64{0}},
+		{query.IDTerm("abc"), []int64{0, 2}},
+		{"trace:abc", []int64{0}},
+		{"level:debug", nil},
+	} {
+		if got := x.Term(tc.term); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Term(%q) = %v, want %v", tc.term, got, tc.want)
+		}
+	}
+}
+
+func TestExpire(t *testing.T) {
+	x := New(4)
+	for i := 0; i < 10; i++ {
+		x.Add(int64(i), models.LogEvent{Message: fmt.Sprintf("common w%d", i), Source: "file"})
+	}
+	if len(x.chunks) != 3 {
+		t.Fatalf("%d chunks, want 3", len(x.chunks))
+	}
+
+	// The first chunk is dropped with its postings; the second is only
+	// partly expired and skipped by lookups
+	x.Expire(5)
+	if len(x.chunks) != 2 || x.chunks[0].start != 4 {
+		t.Fatalf("chunks start at %d (%d), want 4 (2)", x.chunks[0].start, len(x.chunks))
+	}
+	for _, c := range x.chunks {
+		if _, ok := c.words["w1"]; ok {
+			t.Error("an expired word is still indexed")
+		}
+		for _, list := range c.terms {
+			if list[0] < 4 {
+				t.Errorf("postings before 4 are still indexed: %v", list)
+			}
+		}
+	}
+
+	want := []int64{5, 6, 7, 8, 9}
+	if got, _, _ := x.Text("common"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Text = %v, want %v", got, want)
+	}
+	if got := x.Term("source:file"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Term = %v, want %v", got, want)
+	}
+	if got, _, _ := x.Text("w4"); got != nil {
+		t.Errorf("expired w4 found at %v", got)
+	}
+
+	// Expiring never goes back
+	x.Expire(2)
+	if got := x.Term("source:file"); !reflect.DeepEqual(got, want) {
+		t.Errorf("after expiring less, Term = %v", got)
+	}
+
+	x.Expire(12)
+	if len(x.chunks) != 0 || x.Term("source:file") != nil {
+		t.Errorf("%d chunks left after everything expired", len(x.chunks))
+	}
+
+	// Adding continues in a new chunk
+	x.Add(12, models.LogEvent{Message: "common again"})
+	if got, _, _ := x.Text("common"); !reflect.DeepEqual(got, []int64{12}) {
+		t.Errorf("Text after re-adding = %v", got)
+	}
+}
+
+// BenchmarkText measures lookups over 300,000 events with a realistic
+// vocabulary: a few common words, and many numbers and IDs.
+func BenchmarkText(b *testing.B) {
+	const events = 300000
+
+	rng := rand.New(rand.NewSource(1))
+	verbs := []string{"GET", "POST", "PUT", "DELETE"}
+	paths := []string{"/api/users", "/api/orders", "/health", "/api/payments/charge"}
+	outcomes := []string{"completed", "failed: connection refused", "timeout after 3000ms", "ok"}
+
+	x := New(0)
+	for i := 0; i < events; i++ {
+		x.Add(int64(i), models.LogEvent{
+			Source: "file",
+			Message: fmt.Sprintf("%s %s %s request_id=req-%08x user=%d in %dms",
+				verbs[rng.Intn(len(verbs))], paths[rng.Intn(len(paths))],
+				outcomes[rng.Intn(len(outcomes))], rng.Uint32(), rng.Intn(50000), rng.Intn(5000)),
+		})
+	}
+
+	for _, text := range []string{
+		"timeout",              // a common word
+		"refused",              // a word in a quarter of events
+		"req-1a2b",             // a rare partial ID
+		"connection refused",   // a phrase
+		"payments/charge fail", // partial words at both ends
+		"ok",                   // too short for a trigram
+	} {
+		b.Run(text, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				x.Text(text)
+			}
+		})
+	}
+}
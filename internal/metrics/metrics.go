@@ -0,0 +1,158 @@
+// Package metrics counts the events shepai ingests and writes the counts
+// in the Prometheus text exposition format, for /metrics.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+// Metrics counts ingested events and collector problems. A nil *Metrics
+// counts nothing, so collectors run without one.
+type Metrics struct {
+	mu            sync.Mutex
+	events        map[eventKey]int64
+	bytes         map[string]int64 // by source
+	parseFailures map[string]int64 // by source
+	reconnects    map[string]int64 // by source
+}
+
+// eventKey is the labels events are counted by
+type eventKey struct {
+	source, level, stream string
+}
+
+// New creates metrics with every count at zero
+func New() *Metrics {
+	return &Metrics{
+		events:        make(map[eventKey]int64),
+		bytes:         make(map[string]int64),
+		parseFailures: make(map[string]int64),
+		reconnects:    make(map[string]int64),
+	}
+}
+
+// Observe counts an ingested event and the bytes of its lines under its
+// source: the file path or container name, as in models.SourceStatus
+func (m *Metrics) Observe(source string, event models.LogEvent) {
+	if m == nil {
+		return
+	}
+
+	size := len(event.Message) + 1
+	for _, detail := range event.Details {
+		size += len(detail) + 1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[eventKey{source, query.Level(event.Level, event.Message), event.Stream}]++
+	m.bytes[source] += int64(size)
+}
+
+// ParseFailure counts a line from source that none of the configured
+// parsers matched
+func (m *Metrics) ParseFailure(source string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.parseFailures[source]++
+	m.mu.Unlock()
+}
+
+// Reconnect counts a collector reconnecting to its source, e.g. a
+// restarted container or a recreated file, by its name
+func (m *Metrics) Reconnect(source string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.reconnects[source]++
+	m.mu.Unlock()
+}
+
+// Write writes the counts in the Prometheus text format
+func (m *Metrics) Write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := make([]Sample, 0, len(m.events))
+	for key, n := range m.events {
+		events = append(events, Sample{
+			Labels: []Label{{"source", key.source}, {"level", key.level}, {"stream", key.stream}},
+			Value:  n,
+		})
+	}
+	Write(w, "shepai_events_total", Counter, "Log events ingested.", events...)
+	Write(w, "shepai_ingested_bytes_total", Counter, "Bytes of log lines ingested.", bySource(m.bytes)...)
+	Write(w, "shepai_parse_failures_total", Counter, "Lines that none of the configured parsers matched.", bySource(m.parseFailures)...)
+	Write(w, "shepai_collector_reconnects_total", Counter, "Times a collector reconnected to its file or container.", bySource(m.reconnects)...)
+}
+
+func bySource(counts map[string]int64) []Sample {
+	samples := make([]Sample, 0, len(counts))
+	for source, n := range counts {
+		samples = append(samples, Sample{Labels: []Label{{"source", source}}, Value: n})
+	}
+	return samples
+}
+
+// Metric types
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+)
+
+// Label is a label of a sample
+type Label struct {
+	Name, Value string
+}
+
+// Sample is a value of a metric and its labels
+type Sample struct {
+	Labels []Label
+	Value  int64
+}
+
+// Write writes a metric's help, type and samples, sorted by their labels
+func Write(w io.Writer, name, kind, help string, samples ...Sample) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+
+	lines := make([]string, len(samples))
+	for i, s := range samples {
+		var b strings.Builder
+		b.WriteString(name)
+		if len(s.Labels) > 0 {
+			b.WriteByte('{')
+			for j, l := range s.Labels {
+				if j > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(l.Name)
+				b.WriteString(`="`)
+				b.WriteString(labelEscaper.Replace(l.Value))
+				b.WriteByte('"')
+			}
+			b.WriteByte('}')
+		}
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatInt(s.Value, 10))
+		lines[i] = b.String()
+	}
+	sort.Strings(lines)
+
+	for _, line := range lines {
+		io.WriteString(w, line+"\n")
+	}
+}
+
+// labelEscaper escapes label values as the text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+func TestWriteBySource(t *testing.T) {
+	m := New()
+	m.Observe("/var/log/app.log", models.LogEvent{Source: "file", Message: "ERROR boom"})
+	m.Observe("/var/log/app.log", models.LogEvent{Source: "file", Message: "INFO ok"})
+	m.Observe("/var/log/worker.log", models.LogEvent{Source: "file", Message: "INFO ok"})
+	m.ParseFailure("/var/log/worker.log")
+	m.Reconnect("api")
+
+	var b strings.Builder
+	m.Write(&b)
+	out := b.String()
+
+	for _, want := range []string{
+		`shepai_events_total{source="/var/log/app.log",level="error",stream=""} 1`,
+		`shepai_events_total{source="/var/log/app.log",level="info",stream=""} 1`,
+		`shepai_events_total{source="/var/log/worker.log",level="info",stream=""} 1`,
+		`shepai_ingested_bytes_total{source="/var/log/app.log"} 19`,
+		`shepai_ingested_bytes_total{source="/var/log/worker.log"} 8`,
+		`shepai_parse_failures_total{source="/var/log/worker.log"} 1`,
+		`shepai_collector_reconnects_total{source="api"} 1`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `source="file"`) {
+		t.Errorf("counted by collector kind:\n%s", out)
+	}
+}
+
+func TestWriteEscapesLabels(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{`C:\logs\app.log`, `x{source="C:\\logs\\app.log"} 1`},
+		{`say "hi"`, `x{source="say \"hi\""} 1`},
+		{"two\nlines", `x{source="two\nlines"} 1`},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		Write(&b, "x", Counter, "X.", Sample{Labels: []Label{{"source", tt.value}}, Value: 1})
+		if !strings.Contains(b.String(), tt.want+"\n") {
+			t.Errorf("%q written as\n%s", tt.value, b.String())
+		}
+	}
+}
@@ -8,6 +8,49 @@ type LogEvent struct {
 	Source    string    `json:"source"`    // "file" or "docker"
 	Stream    string    `json:"stream"`    // "stdout" or "stderr" (for docker), empty for file
 	Message   string    `json:"message"`
+
+	// Sequence ID assigned by the server, increasing by one per event
+	Seq int64 `json:"seq"`
+
+	// Zone the timestamp was written in (e.g. "Asia/Tokyo" or "+09:00").
+	// Empty when the timestamp is the time shepai received the line.
+	Zone string `json:"zone,omitempty"`
+
+	// ANSI colors and attributes of Message, whose escape sequences have
+	// been stripped
+	Styles []StyleSpan `json:"styles,omitempty"`
+
+	// Continuation lines (e.g. stack frames) grouped into this entry
+	Details []string `json:"details,omitempty"`
+
+	// ANSI colors and attributes of Details, with offsets into the lines
+	// joined by newlines
+	DetailStyles []StyleSpan `json:"detailStyles,omitempty"`
+
+	// Populated when a user-defined parser matched the line
+	Level  string            `json:"level,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// Trace and request IDs found in the entry, keyed by kind ("trace", "request")
+	Correlation map[string]string `json:"correlation,omitempty"`
+
+	// Names of the redaction rules that hid part of this entry
+	Redactions []string `json:"redactions,omitempty"`
+}
+
+// StyleSpan describes the ANSI styling of a range of a message.
+// Start and End are offsets in UTF-16 code units, as used by JavaScript strings.
+// Colors are ANSI color names ("red", "brightBlue", ...) or "#rrggbb".
+type StyleSpan struct {
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	Fg        string `json:"fg,omitempty"`
+	Bg        string `json:"bg,omitempty"`
+	Bold      bool   `json:"bold,omitempty"`
+	Dim       bool   `json:"dim,omitempty"`
+	Italic    bool   `json:"italic,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+	Inverse   bool   `json:"inverse,omitempty"`
 }
 
 // LogCollector defines the interface for log collectors
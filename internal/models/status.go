@@ -0,0 +1,98 @@
+package models
+
+import "time"
+
+// States of a source, as reported by its collector
+const (
+	// SourceStarting is a source that isn't being followed yet
+	SourceStarting = "starting"
+
+	// SourceFollowing is a source whose new lines are being read
+	SourceFollowing = "following"
+
+	// SourceWaiting is a file or container that doesn't exist (yet)
+	SourceWaiting = "waiting"
+
+	// SourceStopped is a container that exists but isn't running
+	SourceStopped = "stopped"
+
+	// SourceReconnecting is a source whose stream failed and is retried
+	SourceReconnecting = "reconnecting"
+
+	// SourceUnknown is a source whose collector doesn't report its state
+	SourceUnknown = "unknown"
+)
+
+// SourceStatus describes what a collector is doing with its source
+type SourceStatus struct {
+	Name  string `json:"name"` // file path or container name
+	Kind  string `json:"kind"` // "file" or "docker"
+	State string `json:"state"`
+
+	// Detail explains the state, e.g. the status of a stopped container
+	Detail string `json:"detail,omitempty"`
+
+	// Attempt counts the attempts to reopen or reconnect to the source since
+	// it was last followed
+	Attempt int `json:"attempt,omitempty"`
+
+	// Since is when the state last changed
+	Since time.Time `json:"since"`
+}
+
+// StatusReporter is implemented by collectors that report the state of
+// their source
+type StatusReporter interface {
+	SourceStatus() SourceStatus
+}
+
+// StatusOf returns the status c reports, or only its name when it reports
+// none
+func StatusOf(c LogCollector) SourceStatus {
+	if r, ok := c.(StatusReporter); ok {
+		return r.SourceStatus()
+	}
+	return SourceStatus{Name: c.GetSourceName(), State: SourceUnknown}
+}
+
+// Status event codes
+const (
+	StatusFileNotFound      = "file_not_found"
+	StatusFileFound         = "file_found"
+	StatusFileRotated       = "file_rotated"
+	StatusContainerNotFound = "container_not_found"
+	StatusContainerStopped  = "container_stopped"
+	StatusStreamFailed      = "stream_failed"
+	StatusConnectionLost    = "connection_lost"
+	StatusReconnected       = "reconnected"
+)
+
+// Status event severities
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// StatusEvent reports something that happened to a source, such as a file
+// being rotated or a container stopping. It's sent apart from log events,
+// so it never shows up in searches or exports.
+type StatusEvent struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"` // file path or container name
+	Kind     string    `json:"kind"`   // "file" or "docker"
+	Code     string    `json:"code"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"` // e.g. "File 'app.log' not found. Waiting for file..."
+
+	// Details are facts about the event, e.g. the container's status or
+	// the error
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// StatusNotifier is implemented by collectors that send status events
+type StatusNotifier interface {
+	// NotifyStatus has the collector send its status events to ch. Events
+	// are dropped rather than block the collector when ch is full.
+	NotifyStatus(ch chan<- StatusEvent)
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/grok"
+)
+
+// GrokParser parses lines with a compiled grok expression
+type GrokParser struct {
+	name       string
+	expr       *grok.Expression
+	timeLayout string
+	loc        *time.Location
+}
+
+// NewGrokParser compiles a grok parser using the patterns in lib.
+// timeLayout is the Go time layout used for the "ts" field and may be empty.
+// Timestamps without a zone offset are read in loc.
+func NewGrokParser(name, expression, timeLayout string, loc *time.Location, lib *grok.Library) (*GrokParser, error) {
+	expr, err := lib.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("parser %q: %w", name, err)
+	}
+
+	hasField := false
+	for _, field := range expr.Fields {
+		if field != "" {
+			hasField = true
+			break
+		}
+	}
+	if !hasField {
+		return nil, fmt.Errorf("parser %q: grok expression captures no fields", name)
+	}
+
+	return &GrokParser{
+		name:       name,
+		expr:       expr,
+		timeLayout: timeLayout,
+		loc:        locationOrUTC(loc),
+	}, nil
+}
+
+// Name returns the rule name
+func (g *GrokParser) Name() string {
+	return g.name
+}
+
+// Parse matches the line against the grok expression
+func (g *GrokParser) Parse(line string) (Result, bool) {
+	match := g.expr.Regexp.FindStringSubmatch(line)
+	if match == nil {
+		return Result{}, false
+	}
+
+	return buildResult(g.expr.Fields, match, g.timeLayout, g.loc), true
+}
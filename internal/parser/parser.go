@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/config"
+	"github.com/monstarlab/shepai/internal/grok"
+)
+
+// Names of the capture groups that map onto LogEvent fields
+const (
+	GroupTimestamp = "ts"
+	GroupLevel     = "level"
+	GroupMessage   = "msg"
+)
+
+// Result holds the structured data extracted from a single log line
+type Result struct {
+	Timestamp time.Time
+	Level     string
+	Message   string
+	Fields    map[string]string
+
+	// TimestampErr is set when the "ts" group matched but could not be parsed
+	TimestampErr error
+}
+
+// Parser extracts structured data from a raw log line
+type Parser interface {
+	// Name returns the rule name the parser was built from
+	Name() string
+
+	// Parse returns the extracted data and whether the line matched
+	Parse(line string) (Result, bool)
+}
+
+// Chain is an ordered list of parsers; the first one that matches wins
+type Chain []Parser
+
+// Parse runs the line through each parser in order and returns the first match
+func (c Chain) Parse(line string) (Result, Parser, bool) {
+	for _, p := range c {
+		if res, ok := p.Parse(line); ok {
+			return res, p, true
+		}
+	}
+	return Result{}, nil, false
+}
+
+// RegexParser parses lines with a regular expression using named capture groups
+type RegexParser struct {
+	name       string
+	re         *regexp.Regexp
+	timeLayout string
+	loc        *time.Location
+}
+
+// NewRegexParser compiles a regex parser.
+// timeLayout is the Go time layout used for the "ts" group and may be empty.
+// Timestamps without a zone offset are read in loc.
+func NewRegexParser(name, pattern, timeLayout string, loc *time.Location) (*RegexParser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("parser %q: invalid pattern: %w", name, err)
+	}
+
+	hasNamedGroup := false
+	for _, group := range re.SubexpNames() {
+		if group != "" {
+			hasNamedGroup = true
+			break
+		}
+	}
+	if !hasNamedGroup {
+		return nil, fmt.Errorf("parser %q: pattern has no named capture groups", name)
+	}
+
+	return &RegexParser{
+		name:       name,
+		re:         re,
+		timeLayout: timeLayout,
+		loc:        locationOrUTC(loc),
+	}, nil
+}
+
+// Name returns the rule name
+func (r *RegexParser) Name() string {
+	return r.name
+}
+
+// Parse matches the line against the pattern
+func (r *RegexParser) Parse(line string) (Result, bool) {
+	match := r.re.FindStringSubmatch(line)
+	if match == nil {
+		return Result{}, false
+	}
+
+	return buildResult(r.re.SubexpNames(), match, r.timeLayout, r.loc), true
+}
+
+// locationOrUTC keeps the historical behaviour of reading zone-less timestamps as UTC
+func locationOrUTC(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// buildResult maps named capture groups onto a Result
+func buildResult(names []string, match []string, timeLayout string, loc *time.Location) Result {
+	var res Result
+
+	for i, name := range names {
+		// Optional groups that didn't participate in the match are skipped, so
+		// an unmatched group never overwrites a value captured under the same name
+		if name == "" || i >= len(match) || match[i] == "" {
+			continue
+		}
+		value := match[i]
+
+		switch name {
+		case GroupTimestamp:
+			if timeLayout == "" {
+				res.TimestampErr = fmt.Errorf("no timeLayout configured for %q", value)
+				continue
+			}
+			t, err := time.ParseInLocation(timeLayout, value, loc)
+			if err != nil {
+				res.TimestampErr = err
+				continue
+			}
+			res.Timestamp = t
+		case GroupLevel:
+			res.Level = strings.ToLower(value)
+		case GroupMessage:
+			res.Message = value
+		default:
+			if res.Fields == nil {
+				res.Fields = make(map[string]string)
+			}
+			res.Fields[name] = value
+		}
+	}
+
+	return res
+}
+
+// New builds a parser from a config rule.
+// lib is only used by grok rules; loc is the zone for timestamps without an offset.
+func New(rule config.ParserRule, lib *grok.Library, loc *time.Location) (Parser, error) {
+	switch rule.Type {
+	case config.ParserTypeGrok:
+		return NewGrokParser(rule.Name, rule.Pattern, rule.TimeLayout, loc, lib)
+	default:
+		return NewRegexParser(rule.Name, rule.Pattern, rule.TimeLayout, loc)
+	}
+}
+
+// NewGrokLibrary returns the base grok library extended with the custom
+// pattern files listed in the config
+func NewGrokLibrary(cfg *config.Config) (*grok.Library, error) {
+	lib := grok.NewLibrary()
+	for _, path := range cfg.GrokPatterns {
+		if err := lib.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return lib, nil
+}
+
+// NewChain builds a parser chain from the config rules, preserving their order
+func NewChain(cfg *config.Config, loc *time.Location) (Chain, error) {
+	lib, err := NewGrokLibrary(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make(Chain, 0, len(cfg.Parsers))
+	for _, rule := range cfg.Parsers {
+		p, err := New(rule, lib, loc)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, p)
+	}
+	return chain, nil
+}
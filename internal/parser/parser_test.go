@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/config"
+	"github.com/monstarlab/shepai/internal/grok"
+)
+
+func TestRegexParser(t *testing.T) {
+	p, err := NewRegexParser("laravel",
+		`^\[(?P<ts>[^\]]+)\] (?P<env>\w+)\.(?P<level>\w+): (?P<msg>.*)$`,
+		"2006-01-02 15:04:05", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, ok := p.Parse("[2024-03-01 10:20:30] production.ERROR: Something broke")
+	if !ok {
+		t.Fatal("line didn't match")
+	}
+	if want := time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC); !res.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", res.Timestamp, want)
+	}
+	if res.Level != "error" {
+		t.Errorf("Level = %q, want %q", res.Level, "error")
+	}
+	if res.Message != "Something broke" {
+		t.Errorf("Message = %q", res.Message)
+	}
+	if res.Fields["env"] != "production" || len(res.Fields) != 1 {
+		t.Errorf("Fields = %v, want only env=production", res.Fields)
+	}
+
+	if _, ok := p.Parse("not a laravel line"); ok {
+		t.Error("unrelated line matched")
+	}
+}
+
+func TestRegexParserErrors(t *testing.T) {
+	for _, pattern := range []string{`(unclosed`, `^\d+ \w+$`} {
+		if _, err := NewRegexParser("bad", pattern, "", nil); err == nil {
+			t.Errorf("NewRegexParser(%q) succeeded", pattern)
+		}
+	}
+}
+
+func TestRegexParserTimestamps(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no zone database:", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		layout string
+		loc    *time.Location
+		line   string
+		want   time.Time
+		bad    bool
+	}{
+		{"utc by default", "2006-01-02 15:04:05", nil, "2024-03-01 10:00:00 x",
+			time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), false},
+		{"zone-less in loc", "2006-01-02 15:04:05", tokyo, "2024-03-01 10:00:00 x",
+			time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC), false},
+		{"offset wins over loc", time.RFC3339, tokyo, "2024-03-01T10:00:00Z x",
+			time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), false},
+		{"no layout", "", nil, "2024-03-01 10:00:00 x", time.Time{}, true},
+		{"wrong layout", time.RFC3339, nil, "2024-03-01 10:00:00 x", time.Time{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := NewRegexParser("ts", `^(?P<ts>\S+(?: \d\d:\d\d:\d\d)?) (?P<msg>.*)$`, tc.layout, tc.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, ok := p.Parse(tc.line)
+			if !ok {
+				t.Fatal("line didn't match")
+			}
+			if tc.bad {
+				if res.TimestampErr == nil {
+					t.Errorf("TimestampErr = nil, Timestamp = %v", res.Timestamp)
+				}
+				return
+			}
+			if res.TimestampErr != nil {
+				t.Fatal(res.TimestampErr)
+			}
+			if !res.Timestamp.Equal(tc.want) {
+				t.Errorf("Timestamp = %v, want %v", res.Timestamp, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegexParserOptionalGroups(t *testing.T) {
+	// The unmatched second "user" alternative mustn't clear the first
+	p, err := NewRegexParser("opt", `^(?:user=(?P<user>\w+)|uid=(?P<uid>\d+)) (?P<msg>.*)$`, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := p.Parse("user=ana logged in")
+	if !ok {
+		t.Fatal("line didn't match")
+	}
+	if res.Fields["user"] != "ana" {
+		t.Errorf("user = %q, want ana", res.Fields["user"])
+	}
+	if _, ok := res.Fields["uid"]; ok {
+		t.Errorf("unmatched uid group is set: %v", res.Fields)
+	}
+}
+
+func TestChain(t *testing.T) {
+	cfg := &config.Config{Parsers: []config.ParserRule{
+		{Name: "json-ish", Pattern: `^\{"msg":"(?P<msg>[^"]*)"\}$`},
+		{Name: "grok", Type: config.ParserTypeGrok, Pattern: `%{LOGLEVEL:level} %{GREEDYDATA:msg}`},
+		{Name: "anything", Pattern: `^(?P<msg>.*)$`},
+	}}
+	chain, err := NewChain(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		line, parser, msg string
+	}{
+		{`{"msg":"hello"}`, "json-ish", "hello"},
+		{"WARN disk almost full", "grok", "disk almost full"},
+		{"plain text", "anything", "plain text"},
+	} {
+		res, p, ok := chain.Parse(tc.line)
+		if !ok {
+			t.Errorf("%q: no parser matched", tc.line)
+			continue
+		}
+		if p.Name() != tc.parser || res.Message != tc.msg {
+			t.Errorf("%q: parsed by %s as %q, want %s and %q", tc.line, p.Name(), res.Message, tc.parser, tc.msg)
+		}
+	}
+}
+
+func TestGrokParserNeedsFields(t *testing.T) {
+	if _, err := NewGrokParser("none", `%{WORD} %{NUMBER}`, "", nil, grok.NewLibrary()); err == nil {
+		t.Error("grok expression without named fields was accepted")
+	}
+}
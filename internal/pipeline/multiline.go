@@ -0,0 +1,333 @@
+package pipeline
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/ansi"
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+const (
+	// DefaultFlushTimeout is how long the last entry is held back waiting
+	// for more continuation lines before it is sent on its own
+	DefaultFlushTimeout = 500 * time.Millisecond
+
+	// DefaultMaxLines caps the continuation lines attached to one entry
+	DefaultMaxLines = 500
+)
+
+// MultilineRule recognises the continuation lines of one ecosystem's stack traces
+type MultilineRule struct {
+	Name string
+
+	// Continue matches lines that always continue the current entry
+	Continue *regexp.Regexp
+
+	// TraceStart matches the line that opens a trace block. Inside a block,
+	// lines matching InTrace continue the entry even when they wouldn't
+	// otherwise look like continuations (e.g. Go's unindented frames).
+	TraceStart *regexp.Regexp
+	InTrace    *regexp.Regexp
+
+	// StartsEntry makes TraceStart lines begin a new entry instead of
+	// attaching to the previous one
+	StartsEntry bool
+}
+
+// MultilineRules are the built-in per-language rules
+var MultilineRules = []MultilineRule{
+	{
+		Name: "php",
+		// #0 /app/vendor/laravel/...(123): ...
+		// [stacktrace] / Stack trace: / {main} / thrown in ...
+		Continue: regexp.MustCompile(`^(?:#\d+\s+|\[stacktrace\]\s*$|\[previous exception\]|Stack trace:|\{main\}|\s*thrown in\s)`),
+	},
+	{
+		Name: "java",
+		// \tat com.example.Foo.bar(Foo.java:42) / ... 12 more / Caused by: ...
+		Continue: regexp.MustCompile(`^(?:\s+at\s+\S|\s*\.\.\.\s+\d+\s+(?:more|common frames omitted)|Caused by:\s|\s*Suppressed:\s)`),
+	},
+	{
+		Name:       "python",
+		TraceStart: regexp.MustCompile(`^Traceback \(most recent call last\):`),
+		// The final "ValueError: boom" line and the chaining messages aren't indented
+		InTrace: regexp.MustCompile(`^(?:\s*$|\s+|[A-Za-z_][\w.]*(?:Error|Exception|Warning|Exit|Interrupt|Iteration)\b|During handling of the above exception|The above exception was the direct cause)`),
+	},
+	{
+		Name:        "go",
+		TraceStart:  regexp.MustCompile(`^(?:panic: |fatal error: |goroutine \d+ \[.*\]:$)`),
+		StartsEntry: true,
+		// goroutine 1 [running]: / main.main() / \t/app/main.go:12 +0x1d / created by ...
+		InTrace: regexp.MustCompile(`^(?:\s*$|\s+|goroutine \d+ \[|[\w./*()\[\]-]+\(.*\)$|created by |\[signal |\[recovered\]|exit status \d+)`),
+	},
+	{
+		Name: "node",
+		// "    at fn (file.js:1:2)", the "^^^" marker under the failing code, [cause]: ...
+		Continue: regexp.MustCompile(`^(?:\s+at\s+\S|\s*\^+\s*$|\s*\[cause\]:)`),
+	},
+}
+
+var (
+	// newEntryRe matches lines that always begin a new entry: lines
+	// starting with a date, optionally bracketed
+	newEntryRe = regexp.MustCompile(`^\s*\[?\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}`)
+
+	// closingRe matches standalone closing punctuation, typically the last
+	// line of a multi-line JSON payload: }, ], ), "}
+	closingRe = regexp.MustCompile(`^[\s"'\]\)\}]+[,;]?\s*$`)
+
+	// indentedRe matches indented lines, which are usually continuations
+	indentedRe = regexp.MustCompile(`^\s+\S`)
+)
+
+// MultilineConfig configures the multiline stage
+type MultilineConfig struct {
+	// Rules limits grouping to the named rules; all built-in rules when empty
+	Rules []string
+
+	// FlushTimeout defaults to DefaultFlushTimeout
+	FlushTimeout time.Duration
+
+	// MaxLines defaults to DefaultMaxLines
+	MaxLines int
+}
+
+// Multiline groups stack traces and other continuation lines into the entry
+// they belong to, emitting one event per logical entry with the continuation
+// lines in Details
+type Multiline struct {
+	rules        []MultilineRule
+	flushTimeout time.Duration
+	maxLines     int
+
+	// held is the grouper of the last batch, whose entry may be continued
+	// by the next batch or the live stream
+	mu   sync.Mutex
+	held *grouper
+}
+
+// NewMultiline creates the multiline stage
+func NewMultiline(cfg MultilineConfig) (*Multiline, error) {
+	m := &Multiline{
+		rules:        MultilineRules,
+		flushTimeout: cfg.FlushTimeout,
+		maxLines:     cfg.MaxLines,
+	}
+
+	if len(cfg.Rules) > 0 {
+		m.rules = nil
+		for _, name := range cfg.Rules {
+			rule, ok := findMultilineRule(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown multiline rule %q", name)
+			}
+			m.rules = append(m.rules, rule)
+		}
+	}
+
+	if m.flushTimeout <= 0 {
+		m.flushTimeout = DefaultFlushTimeout
+	}
+	if m.maxLines <= 0 {
+		m.maxLines = DefaultMaxLines
+	}
+
+	return m, nil
+}
+
+// findMultilineRule looks up a built-in rule by name
+func findMultilineRule(name string) (MultilineRule, bool) {
+	for _, rule := range MultilineRules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return MultilineRule{}, false
+}
+
+// Process groups a batch of events. The last entry is held back, so that a
+// stack trace spanning the snapshot and the live stream stays in one entry:
+// Run sends it once the next entry starts, or after the flush timeout.
+func (m *Multiline) Process(events []models.LogEvent) []models.LogEvent {
+	g := m.take()
+	out := make([]models.LogEvent, 0, len(events))
+
+	for _, event := range events {
+		if done, ok := g.add(event); ok {
+			out = append(out, done)
+		}
+	}
+
+	m.mu.Lock()
+	m.held = g
+	m.mu.Unlock()
+	return out
+}
+
+// take returns the grouper held back by Process, or a new one
+func (m *Multiline) take() *grouper {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g := m.held
+	m.held = nil
+	if g == nil {
+		g = &grouper{m: m}
+	}
+	return g
+}
+
+// Run groups the live stream. The entry being built is sent once the next
+// entry starts, or after the flush timeout if no more lines arrive.
+func (m *Multiline) Run(in <-chan models.LogEvent, out chan<- models.LogEvent, stop <-chan struct{}) {
+	g := m.take()
+
+	timer := time.NewTimer(m.flushTimeout)
+	if g.pending == nil {
+		timer.Stop()
+	}
+
+	for {
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case event := <-in:
+			if done, ok := g.add(event); ok {
+				if !send(out, done, stop) {
+					return
+				}
+			}
+			timer.Reset(m.flushTimeout)
+		case <-timer.C:
+			if done, ok := g.flush(); ok {
+				if !send(out, done, stop) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// grouper holds the entry currently being built
+type grouper struct {
+	m       *Multiline
+	pending *models.LogEvent
+	trace   *MultilineRule // rule whose trace block the pending entry is in
+
+	// detailsLen is the UTF-16 length of the pending entry's Details joined
+	// by newlines, where the styles of the next line start
+	detailsLen int
+}
+
+// add feeds one line into the grouper and returns the previous entry if
+// this line started a new one
+func (g *grouper) add(event models.LogEvent) (models.LogEvent, bool) {
+	line := strings.TrimRight(event.Message, "\r\n")
+
+	if g.pending != nil && g.continues(line, event) {
+		g.attach(line, event)
+		return models.LogEvent{}, false
+	}
+
+	done, ok := g.flush()
+	g.pending = &event
+	g.detailsLen = ansi.Len(strings.Join(event.Details, "\n"))
+	g.trace = nil
+	for i := range g.m.rules {
+		if rule := &g.m.rules[i]; rule.TraceStart != nil && rule.TraceStart.MatchString(line) {
+			g.trace = rule
+			break
+		}
+	}
+	return done, ok
+}
+
+// attach adds a continuation line to the pending entry, along with its
+// colors and any fields or IDs the entry doesn't have yet
+func (g *grouper) attach(line string, event models.LogEvent) {
+	pending := g.pending
+
+	offset := 0
+	if len(pending.Details) > 0 {
+		offset = g.detailsLen + 1 // after the newline joining the lines
+	}
+	pending.Details = append(pending.Details, line)
+	g.detailsLen = offset + ansi.Len(line)
+
+	for _, span := range event.Styles {
+		span.Start += offset
+		span.End = offset + min(span.End, ansi.Len(line))
+		if span.End > span.Start {
+			pending.DetailStyles = append(pending.DetailStyles, span)
+		}
+	}
+
+	pending.Fields = merge(pending.Fields, event.Fields)
+	pending.Correlation = merge(pending.Correlation, event.Correlation)
+}
+
+// merge adds the keys of src that dst doesn't have
+func merge(dst, src map[string]string) map[string]string {
+	for key, value := range src {
+		if _, ok := dst[key]; ok {
+			continue
+		}
+		if dst == nil {
+			dst = maps.Clone(src)
+			break
+		}
+		dst[key] = value
+	}
+	return dst
+}
+
+// continues reports whether line belongs to the pending entry
+func (g *grouper) continues(line string, event models.LogEvent) bool {
+	if event.Source != g.pending.Source || event.Stream != g.pending.Stream {
+		return false
+	}
+	if len(g.pending.Details) >= g.m.maxLines {
+		return false
+	}
+	if newEntryRe.MatchString(line) {
+		return false
+	}
+
+	if g.trace != nil && g.trace.InTrace.MatchString(line) {
+		return true
+	}
+
+	for i := range g.m.rules {
+		rule := &g.m.rules[i]
+		if rule.Continue != nil && rule.Continue.MatchString(line) {
+			return true
+		}
+		if rule.TraceStart != nil && rule.TraceStart.MatchString(line) {
+			if rule.StartsEntry {
+				return false
+			}
+			g.trace = rule
+			return true
+		}
+	}
+
+	return indentedRe.MatchString(line) || closingRe.MatchString(line)
+}
+
+// flush returns the pending entry, if any, and clears it
+func (g *grouper) flush() (models.LogEvent, bool) {
+	if g.pending == nil {
+		return models.LogEvent{}, false
+	}
+
+	done := *g.pending
+	g.pending = nil
+	g.trace = nil
+	return done, true
+}
@@ -0,0 +1,225 @@
+package pipeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// lines returns one file event per line
+func lines(text string) []models.LogEvent {
+	var events []models.LogEvent
+	for _, line := range strings.Split(text, "\n") {
+		events = append(events, models.LogEvent{Source: "file", Message: line})
+	}
+	return events
+}
+
+// grouped flushes the entry held back by Process, as Run would
+func grouped(m *Multiline, events []models.LogEvent) []models.LogEvent {
+	out := m.Process(events)
+	if done, ok := m.take().flush(); ok {
+		out = append(out, done)
+	}
+	return out
+}
+
+func newMultiline(t *testing.T, cfg MultilineConfig) *Multiline {
+	t.Helper()
+	m, err := NewMultiline(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMultilineGrouping(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  [][]string // message, then details, of each entry
+	}{
+		{"php",
+			"[2026-10-16 10:00:00] production.ERROR: boom\n[stacktrace]\n#0 /app/a.php(12): f()\n#1 {main}\n[2026-10-16 10:00:01] production.INFO: ok",
+			[][]string{
+				{"[2026-10-16 10:00:00] production.ERROR: boom", "[stacktrace]", "#0 /app/a.php(12): f()", "#1 {main}"},
+				{"[2026-10-16 10:00:01] production.INFO: ok"},
+			}},
+		{"java",
+			"ERROR Request failed\njava.lang.IllegalStateException: boom\n\tat com.example.Foo.bar(Foo.java:42)\nCaused by: java.io.IOException: x\n\t... 12 more\nINFO next",
+			[][]string{
+				{"ERROR Request failed"},
+				{"java.lang.IllegalStateException: boom", "\tat com.example.Foo.bar(Foo.java:42)", "Caused by: java.io.IOException: x", "\t... 12 more"},
+				{"INFO next"},
+			}},
+		{"python",
+			"ERROR handler failed\nTraceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    main()\nValueError: boom\nINFO next",
+			[][]string{
+				{"ERROR handler failed", "Traceback (most recent call last):", "  File \"app.py\", line 3, in <module>", "    main()", "ValueError: boom"},
+				{"INFO next"},
+			}},
+		{"go",
+			"starting\npanic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:12 +0x1d\nexit status 2\nrestarted",
+			[][]string{
+				{"starting"},
+				{"panic: boom", "", "goroutine 1 [running]:", "main.main()", "\t/app/main.go:12 +0x1d", "exit status 2"},
+				{"restarted"},
+			}},
+		{"node",
+			"TypeError: x is not a function\n    at run (/app/index.js:3:5)\n    at main (/app/index.js:9:1)\nlistening",
+			[][]string{
+				{"TypeError: x is not a function", "    at run (/app/index.js:3:5)", "    at main (/app/index.js:9:1)"},
+				{"listening"},
+			}},
+		{"json payload",
+			"payload: {\n  \"a\": 1\n}\nnext",
+			[][]string{
+				{"payload: {", "  \"a\": 1", "}"},
+				{"next"},
+			}},
+		{"dated lines never continue",
+			"first\n  2026-10-16 10:00:00 indented but dated",
+			[][]string{
+				{"first"},
+				{"  2026-10-16 10:00:00 indented but dated"},
+			}},
+		{"other bracketed lines continue",
+			"first\n  [app] indented",
+			[][]string{
+				{"first", "  [app] indented"},
+			}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out := grouped(newMultiline(t, MultilineConfig{}), lines(tc.input))
+
+			var got [][]string
+			for _, event := range out {
+				got = append(got, append([]string{event.Message}, event.Details...))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMultilineSeparatesStreams(t *testing.T) {
+	events := []models.LogEvent{
+		{Source: "docker", Stream: "stdout", Message: "Error: boom"},
+		{Source: "docker", Stream: "stderr", Message: "    at run (/app/index.js:3:5)"},
+	}
+	if out := grouped(newMultiline(t, MultilineConfig{}), events); len(out) != 2 {
+		t.Errorf("got %d entries, want 2: %v", len(out), out)
+	}
+}
+
+func TestMultilineMaxLines(t *testing.T) {
+	input := "Error: boom" + strings.Repeat("\n    at f (a.js:1:1)", 5)
+	out := grouped(newMultiline(t, MultilineConfig{MaxLines: 3}), lines(input))
+	if len(out) != 2 || len(out[0].Details) != 3 || out[1].Message != "    at f (a.js:1:1)" || len(out[1].Details) != 1 {
+		t.Errorf("got %v", out)
+	}
+}
+
+func TestMultilineRules(t *testing.T) {
+	if _, err := NewMultiline(MultilineConfig{Rules: []string{"cobol"}}); err == nil {
+		t.Error("unknown rule was accepted")
+	}
+
+	// With only the java rule, PHP frames still continue by indentation
+	// but "#0" frames don't
+	m := newMultiline(t, MultilineConfig{Rules: []string{"java"}})
+	out := grouped(m, lines("boom\n#0 /app/a.php(12): f()\n\tat Foo.bar(Foo.java:1)"))
+	if len(out) != 2 || out[1].Message != "#0 /app/a.php(12): f()" {
+		t.Errorf("got %v", out)
+	}
+}
+
+func TestMultilineMergesContinuations(t *testing.T) {
+	events := []models.LogEvent{
+		{Source: "file", Message: "Error: boom", Fields: map[string]string{"user": "ana"}},
+		{Source: "file", Message: "    at run (é.js:3:5)",
+			Styles:      []models.StyleSpan{{Start: 4, End: 6, Fg: "red"}},
+			Fields:      map[string]string{"user": "bob", "queue": "emails"},
+			Correlation: map[string]string{"trace": "abc"}},
+		{Source: "file", Message: "    at main (x.js:9:1)",
+			Styles: []models.StyleSpan{{Start: 0, End: 99, Bold: true}}},
+	}
+	out := grouped(newMultiline(t, MultilineConfig{}), events)
+	if len(out) != 1 {
+		t.Fatalf("got %d entries, want 1", len(out))
+	}
+	event := out[0]
+
+	if want := map[string]string{"user": "ana", "queue": "emails"}; !reflect.DeepEqual(event.Fields, want) {
+		t.Errorf("Fields = %v, want %v", event.Fields, want)
+	}
+	if want := map[string]string{"trace": "abc"}; !reflect.DeepEqual(event.Correlation, want) {
+		t.Errorf("Correlation = %v, want %v", event.Correlation, want)
+	}
+
+	// The second line starts after the first (21 UTF-16 units) and a newline,
+	// and its span is cut off at its end
+	want := []models.StyleSpan{{Start: 4, End: 6, Fg: "red"}, {Start: 22, End: 44, Bold: true}}
+	if !reflect.DeepEqual(event.DetailStyles, want) {
+		t.Errorf("DetailStyles = %+v, want %+v", event.DetailStyles, want)
+	}
+}
+
+func TestMultilineSnapshotHandoff(t *testing.T) {
+	m := newMultiline(t, MultilineConfig{FlushTimeout: 20 * time.Millisecond})
+
+	// The snapshot ends in the middle of a stack trace
+	snapshot := m.Process(lines("started\nError: boom\n    at run (a.js:1:1)"))
+	if len(snapshot) != 1 || snapshot[0].Message != "started" {
+		t.Fatalf("snapshot = %v, want only the complete entry", snapshot)
+	}
+
+	in := make(chan models.LogEvent, 10)
+	out := make(chan models.LogEvent, 10)
+	stop := make(chan struct{})
+	defer close(stop)
+	go m.Run(in, out, stop)
+
+	for _, event := range lines("    at main (a.js:2:1)\nlistening") {
+		in <- event
+	}
+
+	var got []models.LogEvent
+	for len(got) < 2 {
+		select {
+		case event := <-out:
+			got = append(got, event)
+		case <-time.After(time.Second):
+			t.Fatalf("got %v, want 2 events", got)
+		}
+	}
+	if got[0].Message != "Error: boom" || len(got[0].Details) != 2 {
+		t.Errorf("first live entry = %q %q, want the whole trace", got[0].Message, got[0].Details)
+	}
+	if got[1].Message != "listening" {
+		t.Errorf("second live entry = %q", got[1].Message)
+	}
+}
+
+func TestMultilineHeldEntryFlushes(t *testing.T) {
+	m := newMultiline(t, MultilineConfig{FlushTimeout: 20 * time.Millisecond})
+	m.Process(lines("Error: boom"))
+
+	out := make(chan models.LogEvent, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go m.Run(make(chan models.LogEvent), out, stop)
+
+	select {
+	case event := <-out:
+		if event.Message != "Error: boom" {
+			t.Errorf("got %q", event.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the last snapshot entry was never sent")
+	}
+}
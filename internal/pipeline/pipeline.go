@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// Stage transforms the events flowing from a collector to the server
+type Stage interface {
+	// Process transforms a batch of events, such as a collector snapshot
+	Process(events []models.LogEvent) []models.LogEvent
+
+	// Run transforms the live stream from in to out until stop is closed
+	Run(in <-chan models.LogEvent, out chan<- models.LogEvent, stop <-chan struct{})
+}
+
+// Collector wraps a LogCollector so that both its snapshot and its live
+// stream pass through a list of stages, in order
+type Collector struct {
+	models.LogCollector
+
+	stages   []Stage
+	stopOnce sync.Once
+	stopChan chan struct{}
+}
+
+// Wrap returns a collector whose events pass through the given stages
+func Wrap(c models.LogCollector, stages ...Stage) *Collector {
+	return &Collector{
+		LogCollector: c,
+		stages:       stages,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// GetSnapshot returns the wrapped collector's snapshot after processing
+func (c *Collector) GetSnapshot() ([]models.LogEvent, error) {
+	events, err := c.LogCollector.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stage := range c.stages {
+		events = stage.Process(events)
+	}
+	return events, nil
+}
+
+// SourceStatus reports the status of the wrapped collector's source
+func (c *Collector) SourceStatus() models.SourceStatus {
+	return models.StatusOf(c.LogCollector)
+}
+
+// NotifyStatus forwards the wrapped collector's status events to ch, if it
+// sends any. They bypass the stages.
+func (c *Collector) NotifyStatus(ch chan<- models.StatusEvent) {
+	if n, ok := c.LogCollector.(models.StatusNotifier); ok {
+		n.NotifyStatus(ch)
+	}
+}
+
+// Start starts the wrapped collector and chains the stages between it and ch
+func (c *Collector) Start(ch chan<- models.LogEvent) error {
+	if len(c.stages) == 0 {
+		return c.LogCollector.Start(ch)
+	}
+
+	in := make(chan models.LogEvent, 100)
+	if err := c.LogCollector.Start(in); err != nil {
+		return err
+	}
+
+	for i, stage := range c.stages {
+		var out chan models.LogEvent
+		if i == len(c.stages)-1 {
+			go stage.Run(in, ch, c.stopChan)
+			break
+		}
+
+		out = make(chan models.LogEvent, 100)
+		go stage.Run(in, out, c.stopChan)
+		in = out
+	}
+
+	return nil
+}
+
+// Stop stops the stages and the wrapped collector
+func (c *Collector) Stop() error {
+	c.stopOnce.Do(func() {
+		close(c.stopChan)
+	})
+	return c.LogCollector.Stop()
+}
+
+// send delivers an event unless the pipeline is stopping
+func send(out chan<- models.LogEvent, event models.LogEvent, stop <-chan struct{}) bool {
+	select {
+	case out <- event:
+		return true
+	case <-stop:
+		return false
+	}
+}
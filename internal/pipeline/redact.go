@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/redact"
+)
+
+// Redact hides secrets and personal data in events before they reach the
+// server, so they never appear in the dashboard, the snapshot or exports
+type Redact struct {
+	redactor *redact.Redactor
+}
+
+// NewRedact creates the redaction stage
+func NewRedact(redactor *redact.Redactor) *Redact {
+	return &Redact{redactor: redactor}
+}
+
+// Process redacts a batch of events
+func (r *Redact) Process(events []models.LogEvent) []models.LogEvent {
+	for i := range events {
+		r.apply(&events[i])
+	}
+	return events
+}
+
+// Run redacts the live stream
+func (r *Redact) Run(in <-chan models.LogEvent, out chan<- models.LogEvent, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case event := <-in:
+			r.apply(&event)
+			if !send(out, event, stop) {
+				return
+			}
+		}
+	}
+}
+
+// apply redacts the message, continuation lines, parsed fields and
+// correlation IDs of an event, recording the rules that fired in Redactions
+func (r *Redact) apply(event *models.LogEvent) {
+	record := func(fired []string) {
+		for _, name := range fired {
+			if !slices.Contains(event.Redactions, name) {
+				event.Redactions = append(event.Redactions, name)
+			}
+		}
+	}
+
+	message, fired := r.redactor.Redact(event.Message)
+	if len(fired) > 0 {
+		event.Message = message
+		// Style offsets no longer line up with the redacted text
+		event.Styles = nil
+		record(fired)
+	}
+
+	for i, detail := range event.Details {
+		redacted, fired := r.redactor.Redact(detail)
+		event.Details[i] = redacted
+		if len(fired) > 0 {
+			event.DetailStyles = nil
+		}
+		record(fired)
+	}
+
+	for key, value := range event.Fields {
+		redacted, fired := r.redactor.Redact(value)
+		event.Fields[key] = redacted
+		record(fired)
+	}
+
+	// IDs are found before redaction. One that is a secret itself, or part
+	// of one (e.g. the name of an email used as a request ID), is dropped:
+	// a redacted ID would link unrelated events.
+	for kind, id := range event.Correlation {
+		_, fired := r.redactor.Redact(id)
+		if len(fired) > 0 || (len(event.Redactions) > 0 && !mentions(event, id)) {
+			delete(event.Correlation, kind)
+			record(fired)
+		}
+	}
+	if len(event.Correlation) == 0 {
+		event.Correlation = nil
+	}
+}
+
+// mentions reports whether the text of an event still contains s
+func mentions(event *models.LogEvent, s string) bool {
+	if strings.Contains(event.Message, s) {
+		return true
+	}
+	for _, detail := range event.Details {
+		if strings.Contains(detail, s) {
+			return true
+		}
+	}
+	for _, value := range event.Fields {
+		if strings.Contains(value, s) {
+			return true
+		}
+	}
+	return false
+}
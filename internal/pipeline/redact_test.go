@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/monstarlab/shepai/internal/correlate"
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/redact"
+)
+
+func TestRedactStage(t *testing.T) {
+	redactor, err := redact.New(redact.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage := NewRedact(redactor)
+
+	event := models.LogEvent{
+		Message:      "login request_id=jane.doe@example.com trace_id=4bf92f3577b34da6a3ce929d0e0e4736",
+		Styles:       []models.StyleSpan{{Start: 0, End: 5, Fg: "red"}},
+		Details:      []string{"password=hunter2"},
+		DetailStyles: []models.StyleSpan{{Start: 0, End: 8, Bold: true}},
+		Fields:       map[string]string{"user": "ana@example.com"},
+	}
+	event.Correlation = correlate.Extract(event.Message, event.Fields)
+
+	out := stage.Process([]models.LogEvent{event})[0]
+
+	if out.Message != "login request_id=[REDACTED:email] trace_id=4bf92f3577b34da6a3ce929d0e0e4736" || out.Styles != nil {
+		t.Errorf("Message = %q, Styles = %v", out.Message, out.Styles)
+	}
+	if out.Details[0] != "password=[REDACTED:password]" || out.DetailStyles != nil {
+		t.Errorf("Details = %q, DetailStyles = %v", out.Details, out.DetailStyles)
+	}
+	if out.Fields["user"] != "[REDACTED:email]" {
+		t.Errorf("Fields = %v", out.Fields)
+	}
+	if want := []string{"email", "password"}; !reflect.DeepEqual(out.Redactions, want) {
+		t.Errorf("Redactions = %v, want %v", out.Redactions, want)
+	}
+
+	// The request ID was the name of the email
+	if want := map[string]string{"trace": "4bf92f3577b34da6a3ce929d0e0e4736"}; !reflect.DeepEqual(out.Correlation, want) {
+		t.Errorf("Correlation = %v, want %v", out.Correlation, want)
+	}
+}
+
+func TestRedactStageKeepsIDs(t *testing.T) {
+	redactor, err := redact.New(redact.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := "request_id=req-12345 user ana@example.com"
+	event := models.LogEvent{Message: message, Correlation: correlate.Extract(message, nil)}
+	out := NewRedact(redactor).Process([]models.LogEvent{event})[0]
+
+	if want := map[string]string{"request": "req-12345"}; !reflect.DeepEqual(out.Correlation, want) {
+		t.Errorf("Correlation = %v, want %v", out.Correlation, want)
+	}
+
+	// IDs that are secrets themselves are dropped even from clean text
+	event = models.LogEvent{Message: "ok", Correlation: map[string]string{"request": "ana@example.com"}}
+	out = NewRedact(redactor).Process([]models.LogEvent{event})[0]
+	if out.Correlation != nil || !reflect.DeepEqual(out.Redactions, []string{"email"}) {
+		t.Errorf("Correlation = %v, Redactions = %v", out.Correlation, out.Redactions)
+	}
+}
@@ -0,0 +1,149 @@
+// Package printer writes log events to a terminal or a pipe, for running
+// shepai without the web dashboard.
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+// Output formats
+const (
+	// FormatText prints a line per event with its time and level, followed
+	// by its continuation lines
+	FormatText = "text"
+
+	// FormatJSON prints each event as a line of JSON, for piping
+	FormatJSON = "json"
+)
+
+// ValidFormat reports whether format is a supported output format
+func ValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON
+}
+
+// SGR escape sequences used for text output
+const (
+	reset = "\x1b[0m"
+	dim   = "\x1b[2m"
+)
+
+// levelStyles are the tag and color of each level, matching the
+// dashboard's badges
+var levelStyles = map[string]struct {
+	tag   string
+	color string
+}{
+	query.LevelError:   {"ERROR", "\x1b[1;31m"},
+	query.LevelWarning: {"WARN", "\x1b[1;33m"},
+	query.LevelInfo:    {"INFO", "\x1b[1;34m"},
+	query.LevelDebug:   {"DEBUG", "\x1b[90m"},
+	query.LevelSuccess: {"OK", "\x1b[1;32m"},
+}
+
+// LevelTag returns the tag and SGR color of a level for terminal output;
+// both are empty for events without a recognised level
+func LevelTag(level string) (tag, color string) {
+	style := levelStyles[level]
+	return style.tag, style.color
+}
+
+// Options configures a Printer
+type Options struct {
+	// Format is FormatText (the default) or FormatJSON
+	Format string
+
+	// Color adds ANSI colors to text output
+	Color bool
+
+	// Filter selects the events to print; nil prints every event
+	Filter *query.Query
+}
+
+// Printer writes events to w, one at a time so a tail shows each event as
+// it arrives
+type Printer struct {
+	w    io.Writer
+	opts Options
+	buf  bytes.Buffer
+}
+
+// New creates a printer writing to w
+func New(w io.Writer, opts Options) *Printer {
+	if opts.Format == "" {
+		opts.Format = FormatText
+	}
+	return &Printer{w: w, opts: opts}
+}
+
+// Print writes an event if it passes the filter
+func (p *Printer) Print(event models.LogEvent) error {
+	if p.opts.Filter != nil && !p.opts.Filter.Match(event) {
+		return nil
+	}
+
+	p.buf.Reset()
+	if p.opts.Format == FormatJSON {
+		// The level the dashboard shows, also when guessed from the message
+		event.Level = query.Level(event.Level, event.Message)
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		p.buf.Write(data)
+		p.buf.WriteByte('\n')
+	} else {
+		p.formatText(event)
+	}
+
+	_, err := p.w.Write(p.buf.Bytes())
+	return err
+}
+
+// formatText formats an event as its time, level tag and message, with any
+// continuation lines (e.g. stack frames) grouped below it
+func (p *Printer) formatText(event models.LogEvent) {
+	tag, color := LevelTag(query.Level(event.Level, event.Message))
+
+	p.style(dim, event.Timestamp.Format("2006-01-02 15:04:05"))
+	p.buf.WriteByte(' ')
+	p.style(color, fmt.Sprintf("%-5s", tag))
+	p.buf.WriteByte(' ')
+	p.buf.WriteString(event.Message)
+	p.buf.WriteByte('\n')
+
+	for _, detail := range event.Details {
+		p.style(dim, "  │ "+strings.TrimRight(detail, "\r\n"))
+		p.buf.WriteByte('\n')
+	}
+}
+
+// style writes s in an SGR style when colors are on
+func (p *Printer) style(sgr, s string) {
+	if !p.opts.Color || sgr == "" {
+		p.buf.WriteString(s)
+		return
+	}
+	p.buf.WriteString(sgr)
+	p.buf.WriteString(s)
+	p.buf.WriteString(reset)
+}
+
+// IsTerminal reports whether f is a terminal rather than a pipe or file
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ColorEnabled reports whether text written to f should be colored: f is a
+// terminal and NO_COLOR (https://no-color.org) isn't set
+func ColorEnabled(f *os.File) bool {
+	return IsTerminal(f) && os.Getenv("NO_COLOR") == ""
+}
@@ -0,0 +1,152 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// Index looks up the positions of events, ascending, for Query.Candidates
+type Index interface {
+	// Text returns the events whose message or details may contain text
+	// (ignoring case), whether all of them do, and false if the index can't
+	// narrow the search for text
+	Text(text string) (positions []int64, exact, ok bool)
+
+	// Term returns the events having a term returned by Terms
+	Term(term string) []int64
+}
+
+// Terms returns the exact-match terms of an event for an Index: its level,
+// stream, source and correlation IDs
+func Terms(event models.LogEvent) []string {
+	terms := []string{
+		levelTerm(Level(event.Level, event.Message)),
+		streamTerm(event.Stream),
+		sourceTerm(event.Source),
+	}
+	for kind, id := range event.Correlation {
+		terms = append(terms, idTerm("id", id), idTerm(kind, id))
+	}
+	return terms
+}
+
+func levelTerm(level string) string   { return "level:" + level }
+func streamTerm(stream string) string { return "stream:" + strings.ToLower(stream) }
+func sourceTerm(source string) string { return "source:" + strings.ToLower(source) }
+func idTerm(kind, id string) string   { return kind + ":" + id }
+
+// IDTerm is the term of the events carrying a correlation ID of any kind
+func IDTerm(id string) string { return idTerm("id", id) }
+
+// Candidates returns the positions of the events that may match the query,
+// ascending, using idx. If exact is true, all of them match. ok is false
+// when the index can't narrow the query and every event must be checked.
+func (q *Query) Candidates(idx Index) (positions []int64, exact, ok bool) {
+	if q.expr == nil {
+		return nil, false, false
+	}
+	return candidates(q.expr, idx)
+}
+
+func candidates(e expr, idx Index) ([]int64, bool, bool) {
+	switch e := e.(type) {
+	case indexed:
+		return e.lookup(idx)
+
+	case andExpr:
+		var result []int64
+		exact, ok := true, false
+		for _, sub := range e {
+			positions, subExact, subOK := candidates(sub, idx)
+			if !subOK {
+				exact = false
+				continue
+			}
+			if ok {
+				result = intersect(result, positions)
+			} else {
+				result = positions
+			}
+			exact = exact && subExact
+			ok = true
+		}
+		return result, exact && ok, ok
+
+	case orExpr:
+		var result []int64
+		exact := true
+		for _, sub := range e {
+			positions, subExact, subOK := candidates(sub, idx)
+			if !subOK {
+				return nil, false, false
+			}
+			result = union(result, positions)
+			exact = exact && subExact
+		}
+		return result, exact, true
+	}
+
+	return nil, false, false
+}
+
+// indexed is a predicate that an Index can look up
+type indexed struct {
+	predicate
+	lookup func(idx Index) (positions []int64, exact, ok bool)
+}
+
+func termLookup(term string) func(idx Index) ([]int64, bool, bool) {
+	return func(idx Index) ([]int64, bool, bool) {
+		return idx.Term(term), true, true
+	}
+}
+
+// withTerm makes a field predicate indexable when it's an exact comparison
+func withTerm(e expr, op string, v fieldValue, term string) expr {
+	p, isPredicate := e.(predicate)
+	if !isPredicate || v.regex || (op != ":" && op != "=") {
+		return e
+	}
+	return indexed{predicate: p, lookup: termLookup(term)}
+}
+
+// intersect returns the positions in both ascending lists
+func intersect(a, b []int64) []int64 {
+	var result []int64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// union returns the positions in either ascending list
+func union(a, b []int64) []int64 {
+	result := make([]int64, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			result = append(result, a[i])
+			i++
+		case a[i] > b[j]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
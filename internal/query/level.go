@@ -0,0 +1,93 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Levels, as shown by the dashboard's level badges
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelInfo    = "info"
+	LevelDebug   = "debug"
+	LevelSuccess = "success"
+	LevelDefault = "default"
+)
+
+// Level returns the level of an event: the level a parser extracted,
+// normalised, or else a guess from the words of the message. It mirrors the
+// dashboard's resolveSeverityLevel so searches agree with the badges.
+func Level(level, message string) string {
+	if normalized, ok := normalizeLevel(level); ok {
+		return normalized
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, guess := range levelGuesses {
+		for _, word := range words {
+			if guess.matches(word) {
+				return guess.level
+			}
+		}
+	}
+	return LevelDefault
+}
+
+// levelGuess is a level and the words that suggest it. Words match whole,
+// or with any ending ("errors", "warning") or beginning ("TypeError",
+// "IllegalStateException") where allowed, so "ok" doesn't match "token".
+type levelGuess struct {
+	level    string
+	words    []string
+	prefixes []string // words starting with one of these
+	suffixes []string // words ending with one of these
+}
+
+// levelGuesses are tried in order, so a message with both an error and a
+// warning is an error
+var levelGuesses = []levelGuess{
+	{level: LevelError, prefixes: []string{"error", "fatal", "exception"}, suffixes: []string{"error", "exception"}},
+	{level: LevelWarning, prefixes: []string{"warn"}},
+	{level: LevelInfo, prefixes: []string{"info"}},
+	{level: LevelDebug, prefixes: []string{"debug"}},
+	{level: LevelSuccess, words: []string{"ok"}, prefixes: []string{"success"}},
+}
+
+func (g levelGuess) matches(word string) bool {
+	for _, w := range g.words {
+		if word == w {
+			return true
+		}
+	}
+	for _, prefix := range g.prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range g.suffixes {
+		if strings.HasSuffix(word, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeLevel maps level names such as "err", "crit" or "notice" onto a level
+func normalizeLevel(level string) (string, bool) {
+	switch strings.ToLower(level) {
+	case "emergency", "alert", "critical", "crit", "fatal", "error", "err":
+		return LevelError, true
+	case "warning", "warn":
+		return LevelWarning, true
+	case "notice", "info":
+		return LevelInfo, true
+	case "debug", "trace":
+		return LevelDebug, true
+	case "success", "default":
+		return strings.ToLower(level), true
+	}
+	return "", false
+}
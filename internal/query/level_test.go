@@ -0,0 +1,44 @@
+package query
+
+import "testing"
+
+func TestLevel(t *testing.T) {
+	for _, tc := range []struct {
+		level, message, want string
+	}{
+		// Parsed levels are normalised and win over the message
+		{"ERR", "all ok", LevelError},
+		{"crit", "", LevelError},
+		{"warning", "", LevelWarning},
+		{"notice", "error", LevelInfo},
+		{"trace", "", LevelDebug},
+		{"success", "", LevelSuccess},
+		{"verbose", "an error", LevelError}, // unknown: guessed
+
+		// Guesses from the message
+		{"", "ERROR payment failed", LevelError},
+		{"", "3 errors found", LevelError},
+		{"", "TypeError: x is not a function", LevelError},
+		{"", "java.lang.IllegalStateException: boom", LevelError},
+		{"", "Fatal: out of memory", LevelError},
+		{"", "[warn] disk at 91%", LevelWarning},
+		{"", "Warning: deprecated", LevelWarning},
+		{"", "some information", LevelInfo},
+		{"", "debug_mode=true", LevelDebug},
+		{"", "payment successful", LevelSuccess},
+		{"", "GET /health OK", LevelSuccess},
+		{"", "[ok] done", LevelSuccess},
+		{"", "an error and a warning", LevelError},
+
+		// Words merely containing a keyword
+		{"", "refreshing token", LevelDefault},
+		{"", "broken pipe", LevelDefault},
+		{"", "looked up 3 users", LevelDefault},
+		{"", "terrors of the mirror", LevelDefault},
+		{"", "", LevelDefault},
+	} {
+		if got := Level(tc.level, tc.message); got != tc.want {
+			t.Errorf("Level(%q, %q) = %q, want %q", tc.level, tc.message, got, tc.want)
+		}
+	}
+}
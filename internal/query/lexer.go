@@ -0,0 +1,150 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenPhrase // "quoted text"
+	tokenRegex  // /pattern/
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+
+	// For words ending in a field operator followed by a quoted or regex
+	// value, as in msg:"a b" or msg:/err(or)?/
+	value     string
+	valueKind tokenKind
+}
+
+// lex splits a query into tokens. Words run until whitespace or a paren.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == '"':
+			text, end, err := readQuoted(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, text: text, pos: i})
+			i = end
+		case c == '/':
+			text, end, err := readRegex(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenRegex, text: text, pos: i})
+			i = end
+		case strings.HasPrefix(input[i:], "&&"):
+			tokens = append(tokens, token{kind: tokenAnd, text: "&&", pos: i})
+			i += 2
+		case strings.HasPrefix(input[i:], "||"):
+			tokens = append(tokens, token{kind: tokenOr, text: "||", pos: i})
+			i += 2
+		case c == '!' || (c == '-' && i+1 < len(input) && !unicode.IsSpace(rune(input[i+1]))):
+			tokens = append(tokens, token{kind: tokenNot, text: input[i : i+1], pos: i})
+			i++
+		default:
+			start := i
+			for i < len(input) && !strings.ContainsRune(" \t\n\r()", rune(input[i])) {
+				// A quoted or regex value right after a field operator ends the word
+				if (input[i] == '"' || input[i] == '/') && i > start && strings.ContainsRune(":=<>", rune(input[i-1])) {
+					break
+				}
+				i++
+			}
+
+			tok := token{kind: tokenWord, text: input[start:i], pos: start}
+			if i < len(input) && (input[i] == '"' || input[i] == '/') {
+				var err error
+				if input[i] == '"' {
+					tok.valueKind = tokenPhrase
+					tok.value, i, err = readQuoted(input, i)
+				} else {
+					tok.valueKind = tokenRegex
+					tok.value, i, err = readRegex(input, i)
+				}
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, tok)
+				continue
+			}
+
+			switch tok.text {
+			case "AND":
+				tok.kind = tokenAnd
+			case "OR":
+				tok.kind = tokenOr
+			case "NOT":
+				tok.kind = tokenNot
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+// readQuoted reads a double-quoted string starting at input[start], handling
+// \" and \\ escapes, and returns its contents and the index after it
+func readQuoted(input string, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			if i+1 < len(input) {
+				i++
+				b.WriteByte(input[i])
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(input[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quote at position %d", start)
+}
+
+// readRegex reads a /pattern/ starting at input[start]; \/ escapes a slash
+func readRegex(input string, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		switch {
+		case input[i] == '\\' && i+1 < len(input) && input[i+1] == '/':
+			b.WriteByte('/')
+			i++
+		case input[i] == '/':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(input[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated regex at position %d", start)
+}
@@ -0,0 +1,505 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// Query is a parsed search query. The syntax is:
+//
+//	timeout                  free text, case-insensitive, in the message or details
+//	"connection refused"     phrase
+//	/timeout after \d+ms/    regular expression
+//	level:error              level (error, warning, info, debug, success)
+//	stream:stderr            stream; also source:docker
+//	msg:"user created"       message only; also msg:/regex/
+//	field.user_id=42         parsed field; also != > >= < <= and field.x:/regex/
+//	id:4bf92f35...           trace or request ID
+//	after:2026-10-16T10:00   time range; also before:, since:15m and time>=...
+//	a AND b, a b, a OR b, NOT a, -a, (a OR b) c
+//
+// Times without an offset are in UTC, like log timestamps without one
+// under the default --tz. AND binds tighter than OR. Words that look like a field but use an
+// unknown name (e.g. 10:00:01 or http://host) are searched as text.
+type Query struct {
+	expr expr
+	raw  string
+	key  string
+}
+
+// Parse parses a query. An empty query matches every event.
+func Parse(input string) (*Query, error) {
+	return parse(input, time.Now().UTC())
+}
+
+func parse(input string, now time.Time) (*Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, now: now}
+	q := &Query{raw: input}
+
+	if p.peek().kind == tokenEOF {
+		return q, nil
+	}
+
+	q.expr, err = p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	q.key = input
+	for _, t := range p.relative {
+		q.key += "\x00" + t.Format(time.RFC3339Nano)
+	}
+	return q, nil
+}
+
+// Match reports whether an event matches the query
+func (q *Query) Match(event models.LogEvent) bool {
+	return q.expr == nil || q.expr.match(event)
+}
+
+// Empty reports whether the query matches every event
+func (q *Query) Empty() bool {
+	return q.expr == nil
+}
+
+// String returns the query as written
+func (q *Query) String() string {
+	return q.raw
+}
+
+// Key identifies what the query matches: queries with the same key match the
+// same events. Unlike String, it tells apart relative times such as since:15m
+// parsed at different moments.
+func (q *Query) Key() string {
+	return q.key
+}
+
+// expr is a node of a parsed query
+type expr interface {
+	match(event models.LogEvent) bool
+}
+
+type andExpr []expr
+
+func (e andExpr) match(event models.LogEvent) bool {
+	for _, sub := range e {
+		if !sub.match(event) {
+			return false
+		}
+	}
+	return true
+}
+
+type orExpr []expr
+
+func (e orExpr) match(event models.LogEvent) bool {
+	for _, sub := range e {
+		if sub.match(event) {
+			return true
+		}
+	}
+	return false
+}
+
+type notExpr struct{ expr }
+
+func (e notExpr) match(event models.LogEvent) bool {
+	return !e.expr.match(event)
+}
+
+// predicate is a leaf of a parsed query
+type predicate func(event models.LogEvent) bool
+
+func (p predicate) match(event models.LogEvent) bool {
+	return p(event)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	now    time.Time
+
+	// relative are the times relative to now, as resolved
+	relative []time.Time
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// parseOr parses: and (OR and)*
+func (p *parser) parseOr() (expr, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := orExpr{first}
+	for p.peek().kind == tokenOr {
+		p.next()
+		term, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return terms, nil
+}
+
+// parseAnd parses: not ((AND)? not)*
+func (p *parser) parseAnd() (expr, error) {
+	first, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := andExpr{first}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenEOF, tokenOr, tokenRParen:
+			if len(terms) == 1 {
+				return first, nil
+			}
+			return terms, nil
+		}
+
+		term, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+}
+
+// parseNot parses: NOT not | primary
+func (p *parser) parseNot() (expr, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		sub, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{sub}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses: ( or ) | term
+func (p *parser) parsePrimary() (expr, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenLParen:
+		sub, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, fmt.Errorf("missing ) for ( at position %d", tok.pos)
+		}
+		return sub, nil
+	case tokenPhrase:
+		return textPredicate(tok.text), nil
+	case tokenRegex:
+		re, err := compileRegex(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		return regexPredicate(re), nil
+	case tokenWord:
+		return p.parseWord(tok)
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// fieldOpRe splits a word into a field name, an operator and a value
+var fieldOpRe = regexp.MustCompile(`^([A-Za-z_][\w.-]*?)(!=|>=|<=|[:=<>])(.*)$`)
+
+// parseWord parses a free-text word or a field term such as level:error
+func (p *parser) parseWord(tok token) (expr, error) {
+	m := fieldOpRe.FindStringSubmatch(tok.text)
+	if m == nil {
+		return textPredicate(tok.text + tok.value), nil
+	}
+
+	name, op, value := strings.ToLower(m[1]), m[2], m[3]
+	if tok.valueKind != tokenEOF {
+		value = tok.value
+	}
+
+	v := fieldValue{text: value, regex: tok.valueKind == tokenRegex}
+	if v.regex {
+		re, err := compileRegex(value)
+		if err != nil {
+			return nil, err
+		}
+		v.re = re
+	}
+
+	switch {
+	case name == "level":
+		want, ok := normalizeLevel(value)
+		if !ok {
+			return nil, fmt.Errorf("unknown level %q", value)
+		}
+		e, err := fieldPredicate(op, v.withText(want), func(e models.LogEvent) (string, bool) {
+			return Level(e.Level, e.Message), true
+		})
+		return withTerm(e, op, v, levelTerm(want)), err
+	case name == "stream":
+		e, err := fieldPredicate(op, v, func(e models.LogEvent) (string, bool) { return e.Stream, true })
+		return withTerm(e, op, v, streamTerm(value)), err
+	case name == "source":
+		e, err := fieldPredicate(op, v, func(e models.LogEvent) (string, bool) { return e.Source, true })
+		return withTerm(e, op, v, sourceTerm(value)), err
+	case name == "msg" || name == "message":
+		if op == ":" && !v.regex {
+			return messagePredicate(value), nil
+		}
+		return fieldPredicate(op, v, func(e models.LogEvent) (string, bool) { return e.Message, true })
+	case name == "id" || name == "trace" || name == "request":
+		return idPredicate(name, value), nil
+	case strings.HasPrefix(name, "field.") || strings.HasPrefix(name, "fields."):
+		key := m[1][strings.IndexByte(m[1], '.')+1:]
+		return fieldPredicate(op, v, func(e models.LogEvent) (string, bool) {
+			value, ok := e.Fields[key]
+			return value, ok
+		})
+	case name == "after" || name == "since" || name == "before" || name == "time" || name == "ts" || name == "timestamp":
+		return p.timePredicate(name, op, value)
+	}
+
+	// Not a known field: search the word as written
+	return textPredicate(tok.text + tok.value), nil
+}
+
+// fieldValue is the right-hand side of a field term
+type fieldValue struct {
+	text  string
+	regex bool
+	re    *regexp.Regexp
+}
+
+func (v fieldValue) withText(text string) fieldValue {
+	v.text = text
+	return v
+}
+
+// fieldPredicate compares the value returned by get with v using op.
+// : and = match case-insensitively; < and > compare numerically when both
+// sides are numbers, and as strings otherwise.
+func fieldPredicate(op string, v fieldValue, get func(models.LogEvent) (string, bool)) (expr, error) {
+	if v.regex && op != ":" && op != "=" && op != "!=" {
+		return nil, fmt.Errorf("operator %s can't be used with a regex", op)
+	}
+
+	equal := func(actual string) bool {
+		if v.regex {
+			return v.re.MatchString(actual)
+		}
+		return strings.EqualFold(actual, v.text)
+	}
+
+	return predicate(func(e models.LogEvent) bool {
+		actual, ok := get(e)
+		if !ok {
+			return false
+		}
+
+		switch op {
+		case ":", "=":
+			return equal(actual)
+		case "!=":
+			return !equal(actual)
+		}
+
+		cmp := compareValues(actual, v.text)
+		switch op {
+		case ">":
+			return cmp > 0
+		case ">=":
+			return cmp >= 0
+		case "<":
+			return cmp < 0
+		default: // <=
+			return cmp <= 0
+		}
+	}), nil
+}
+
+// compareValues compares two field values, numerically if both are numbers
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// textPredicate matches text anywhere in the message or details, ignoring case
+func textPredicate(text string) expr {
+	lower := strings.ToLower(text)
+	return indexed{lookup: textLookup(text), predicate: func(e models.LogEvent) bool {
+		if strings.Contains(strings.ToLower(e.Message), lower) {
+			return true
+		}
+		for _, detail := range e.Details {
+			if strings.Contains(strings.ToLower(detail), lower) {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// messagePredicate matches text in the message only, ignoring case
+func messagePredicate(text string) expr {
+	lower := strings.ToLower(text)
+	return indexed{lookup: inexact(textLookup(text)), predicate: func(e models.LogEvent) bool {
+		return strings.Contains(strings.ToLower(e.Message), lower)
+	}}
+}
+
+// textLookup looks text up in the index
+func textLookup(text string) func(idx Index) ([]int64, bool, bool) {
+	return func(idx Index) ([]int64, bool, bool) {
+		return idx.Text(text)
+	}
+}
+
+// inexact marks a lookup's results as candidates to be checked
+func inexact(lookup func(idx Index) ([]int64, bool, bool)) func(idx Index) ([]int64, bool, bool) {
+	return func(idx Index) ([]int64, bool, bool) {
+		positions, _, ok := lookup(idx)
+		return positions, false, ok
+	}
+}
+
+// regexPredicate matches a regex against the message or any detail line
+func regexPredicate(re *regexp.Regexp) expr {
+	return predicate(func(e models.LogEvent) bool {
+		if re.MatchString(e.Message) {
+			return true
+		}
+		for _, detail := range e.Details {
+			if re.MatchString(detail) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// idPredicate matches a correlation ID; kind "id" matches any kind
+func idPredicate(kind, id string) expr {
+	return indexed{lookup: termLookup(idTerm(kind, id)), predicate: func(e models.LogEvent) bool {
+		for k, value := range e.Correlation {
+			if (kind == "id" || k == kind) && value == id {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// timePredicate parses after:, before:, since: and time comparisons
+func (p *parser) timePredicate(name, op, value string) (expr, error) {
+	t, err := ParseTime(value, p.now)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := time.ParseDuration(value); err == nil {
+		p.relative = append(p.relative, t)
+	}
+
+	switch name {
+	case "after", "since":
+		op = ">="
+	case "before":
+		op = "<"
+	}
+
+	switch op {
+	case ">":
+		return predicate(func(e models.LogEvent) bool { return e.Timestamp.After(t) }), nil
+	case ">=":
+		return predicate(func(e models.LogEvent) bool { return !e.Timestamp.Before(t) }), nil
+	case "<":
+		return predicate(func(e models.LogEvent) bool { return e.Timestamp.Before(t) }), nil
+	case "<=":
+		return predicate(func(e models.LogEvent) bool { return !e.Timestamp.After(t) }), nil
+	}
+	return nil, fmt.Errorf("time needs one of > >= < <=, not %s", op)
+}
+
+// timeLayouts are the accepted absolute time formats
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTime parses a time as written in queries: an absolute time, or a
+// duration before now such as 15m. Absolute times without an offset are in
+// now's location.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q (use e.g. 2026-10-16T10:00:00Z, 2026-10-16 10:00 or 15m)", value)
+}
+
+// compileRegex compiles a query regex with a clearer error
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex /%s/: %w", pattern, err)
+	}
+	return re, nil
+}
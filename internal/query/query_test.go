@@ -0,0 +1,235 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+var testNow = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+// testEvents are matched by the queries in TestParse, which list them by index
+var testEvents = []models.LogEvent{
+	{ // 0
+		Timestamp: testNow.Add(-time.Hour),
+		Source:    "file",
+		Message:   "ERROR payment failed: connection refused",
+		Details:   []string{"\tat Pay.charge(Pay.java:12)"},
+		Fields:    map[string]string{"user_id": "42", "queue": "emails"},
+	},
+	{ // 1
+		Timestamp:   testNow.Add(-10 * time.Minute),
+		Source:      "docker",
+		Stream:      "stderr",
+		Message:     "WARN payment timeout after 3000ms",
+		Fields:      map[string]string{"user_id": "7"},
+		Correlation: map[string]string{"trace": "4bf92f35", "request": "req-1"},
+	},
+	{ // 2
+		Timestamp: testNow.Add(-5 * time.Minute),
+		Source:    "docker",
+		Stream:    "stdout",
+		Level:     "notice",
+		Message:   `user "ana" created at 10:00:01 via http://host/users`,
+	},
+	{ // 3
+		Timestamp: testNow,
+		Source:    "file",
+		Message:   "GET /health OK",
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{0, 1, 2, 3}},
+		{"   ", []int{0, 1, 2, 3}},
+
+		// Text, phrases and regexes
+		{"payment", []int{0, 1}},
+		{"PAYMENT", []int{0, 1}},
+		{"pay.java", []int{0}}, // in the details
+		{`"connection refused"`, []int{0}},
+		{`"refused connection"`, nil},
+		{`"user \"ana\""`, []int{2}},
+		{`/timeout after \d+ms/`, []int{1}},
+		{`/a\/b|users/`, []int{2}},
+		{"10:00:01", []int{2}},          // not a field
+		{"http://host/users", []int{2}}, // nor this
+
+		// Fields
+		{"level:error", []int{0}},
+		{"level:err", []int{0}},
+		{"level:info", []int{2}}, // notice
+		{"level:success", []int{3}},
+		{"stream:stderr", []int{1}},
+		{"source:DOCKER", []int{1, 2}},
+		{`msg:"connection refused"`, []int{0}},
+		{"msg:pay.java", nil}, // only in the details
+		{"msg:/^WARN/", []int{1}},
+		{"field.user_id=42", []int{0}},
+		{"field.user_id!=42", []int{1}},
+		{"field.user_id>8", []int{0}}, // numerically, not "7" > "8"
+		{"field.user_id<=7", []int{1}},
+		{"fields.queue:EMAILS", []int{0}},
+		{"field.queue:/^em/", []int{0}},
+		{"field.missing!=x", nil},
+		{"id:4bf92f35", []int{1}},
+		{"trace:4bf92f35", []int{1}},
+		{"request:4bf92f35", nil},
+		{"request:req-1", []int{1}},
+
+		// Times
+		{"since:15m", []int{1, 2, 3}},
+		{"after:2026-10-16T11:55", []int{2, 3}},
+		{"after:2026-10-16T20:55:00+09:00", []int{2, 3}},
+		{"before:2026-10-16T11:55:00", []int{0, 1}},
+		{"time>2026-10-16T11:55:00Z", []int{3}},
+		{"time<=2026-10-16T11:50:00Z", []int{0, 1}},
+		{"after:2026-10-17", nil},
+
+		// Negation
+		{"-payment", []int{2, 3}},
+		{"!payment", []int{2, 3}},
+		{"NOT payment", []int{2, 3}},
+		{"NOT NOT payment", []int{0, 1}},
+		{"-level:error payment", []int{1}},
+		{"a - b", nil}, // a lone - is a word
+
+		// AND, OR and precedence
+		{"payment timeout", []int{1}},
+		{"payment AND timeout", []int{1}},
+		{"payment && timeout", []int{1}},
+		{"refused OR created", []int{0, 2}},
+		{"refused || created", []int{0, 2}},
+		{"health OR payment timeout", []int{1, 3}}, // health OR (payment timeout)
+		{"payment timeout OR health", []int{1, 3}},
+		{"(health OR payment) timeout", []int{1}},
+		{"-(payment OR health)", []int{2}},
+		{"source:docker (level:warning OR level:info) -user", []int{1}},
+		{"payment and timeout", nil}, // lowercase "and" is a word
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := parse(tc.query, testNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for i, event := range testEvents {
+				if q.Match(event) {
+					got = append(got, i)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("matched %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		err   string
+	}{
+		{`"unterminated`, "unterminated quote at position 0"},
+		{`msg:/unterminated`, "unterminated regex at position 4"},
+		{`/(/`, "invalid regex"},
+		{"(a OR b", "missing ) for ( at position 0"},
+		{"a)", `unexpected ")" at position 1`},
+		{"a OR", "unexpected end of query"},
+		{"NOT", "unexpected end of query"},
+		{"level:loud", `unknown level "loud"`},
+		{"field.x>/a/", "can't be used with a regex"},
+		{"after:yesterday", `invalid time "yesterday"`},
+		{"time:2026-10-16", "time needs one of"},
+	} {
+		_, err := parse(tc.query, testNow)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%q: got error %v, want %q", tc.query, err, tc.err)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no zone database:", err)
+	}
+
+	for _, tc := range []struct {
+		value string
+		now   time.Time
+		want  time.Time
+	}{
+		{"15m", testNow, testNow.Add(-15 * time.Minute)},
+		{"1h30m", testNow, testNow.Add(-90 * time.Minute)},
+		{"2026-10-16T10:00:00.5Z", testNow, time.Date(2026, 10, 16, 10, 0, 0, 5e8, time.UTC)},
+		{"2026-10-16T10:00:00+09:00", testNow, time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC)},
+		{"2026-10-16T10:00:00", testNow, time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)},
+		{"2026-10-16 10:00", testNow, time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)},
+		{"2026-10-16", testNow, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		// Without an offset, in now's location
+		{"2026-10-16 10:00", testNow.In(tokyo), time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC)},
+		{"2026-10-16T10:00:00Z", testNow.In(tokyo), time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)},
+	} {
+		got, err := ParseTime(tc.value, tc.now)
+		if err != nil || !got.Equal(tc.want) {
+			t.Errorf("ParseTime(%q) in %v = %v %v, want %v", tc.value, tc.now.Location(), got, err, tc.want)
+		}
+	}
+
+	if _, err := ParseTime("16/10/2026", testNow); err == nil {
+		t.Error("unknown format was accepted")
+	}
+}
+
+func TestParseIsUTC(t *testing.T) {
+	defer func(orig *time.Location) { time.Local = orig }(time.Local)
+	time.Local = time.FixedZone("test", 9*3600)
+
+	q, err := Parse("after:2026-10-16T10:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Match(models.LogEvent{Timestamp: time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)}) ||
+		q.Match(models.LogEvent{Timestamp: time.Date(2026, 10, 16, 9, 59, 0, 0, time.UTC)}) {
+		t.Error("a time without an offset wasn't read as UTC")
+	}
+}
+
+func TestKey(t *testing.T) {
+	later := testNow.Add(time.Minute)
+	for _, tc := range []struct {
+		a, b  string
+		nowB  time.Time
+		equal bool
+	}{
+		{"level:error", "level:error", later, true},
+		{"after:2026-10-16T10:00:00Z", "after:2026-10-16T10:00:00Z", later, true},
+		{"since:15m", "since:15m", testNow, true},
+		// The same words, but a different window
+		{"since:15m", "since:15m", later, false},
+		{"level:error", "level:warning", testNow, false},
+	} {
+		a, err := parse(tc.a, testNow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := parse(tc.b, tc.nowB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equal := a.Key() == b.Key(); equal != tc.equal {
+			t.Errorf("%q and %q at %v: same key = %v", tc.a, tc.b, tc.nowB, equal)
+		}
+		if a.String() != tc.a {
+			t.Errorf("String() = %q, want %q", a.String(), tc.a)
+		}
+	}
+}
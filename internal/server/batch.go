@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+const (
+	// maxBatchSize is the most events sent in one message
+	maxBatchSize = 256
+
+	// batchWindow is how long the first event of a batch waits for more
+	batchWindow = 10 * time.Millisecond
+)
+
+// batch is a group of live events sent together as one "events" message.
+// Each distinct message is shared by every client that receives it.
+type batch struct {
+	events []models.LogEvent
+
+	all      *eventsMessage
+	filtered map[string]*eventsMessage // by filter key; nil when none match
+}
+
+func newBatch(events []models.LogEvent) *batch {
+	return &batch{
+		events:   events,
+		filtered: make(map[string]*eventsMessage),
+	}
+}
+
+// allEvents returns the message with every event of the batch
+func (b *batch) allEvents() *eventsMessage {
+	if b.all == nil {
+		b.all = &eventsMessage{events: b.events}
+	}
+	return b.all
+}
+
+// matching returns the message with the events of the batch matching
+// filter, or nil when there are none
+func (b *batch) matching(filter *query.Query) *eventsMessage {
+	key := filter.Key()
+	if m, ok := b.filtered[key]; ok {
+		return m
+	}
+
+	var events []models.LogEvent
+	for _, event := range b.events {
+		if filter.Match(event) {
+			events = append(events, event)
+		}
+	}
+
+	var m *eventsMessage
+	if len(events) > 0 {
+		m = &eventsMessage{events: events}
+	}
+	b.filtered[key] = m
+	return m
+}
+
+// eventsMessage is an "events" message of live events. It's encoded once,
+// by the first WebSocket writer to send it, for any number of clients.
+type eventsMessage struct {
+	events []models.LogEvent
+
+	once     sync.Once
+	prepared *websocket.PreparedMessage
+	err      error
+}
+
+func (m *eventsMessage) encode() (*websocket.PreparedMessage, error) {
+	m.once.Do(func() {
+		data, err := json.Marshal(map[string]interface{}{
+			"type":   "events",
+			"events": m.events,
+		})
+		if err != nil {
+			m.err = err
+			return
+		}
+		m.prepared, m.err = websocket.NewPreparedMessage(websocket.TextMessage, data)
+	})
+	return m.prepared, m.err
+}
+
+// broadcast sends live events to clients in batches: a batch is sent when
+// it reaches batchSize events, or batchWindow after its first event. Once
+// stopBroadcast is closed it stores the events it holds and returns.
+func (s *Server) broadcast() {
+	timer := time.NewTimer(s.batchWindow)
+	timer.Stop()
+
+	defer close(s.broadcastDone)
+
+	var events []models.LogEvent
+	for {
+		select {
+		case event, ok := <-s.eventChan:
+			if !ok {
+				if err := s.flush(events); err != nil {
+					log.Printf("Error storing events: %v", err)
+				}
+				return
+			}
+			if len(events) == 0 {
+				timer.Reset(s.batchWindow)
+			}
+			events = append(events, event)
+			if len(events) < s.batchSize {
+				continue
+			}
+		case <-timer.C:
+		case <-s.stopBroadcast:
+			// Store what the collector sent before it stopped
+			for pending := true; pending; {
+				select {
+				case event := <-s.eventChan:
+					events = append(events, event)
+				default:
+					pending = false
+				}
+			}
+			if err := s.flush(events); err != nil {
+				log.Printf("Error storing events: %v", err)
+			}
+			return
+		}
+
+		timer.Stop()
+		if err := s.flush(events); err != nil {
+			// Stop ingesting: events that can't be stored would get sequence
+			// IDs the store can't serve
+			s.storeErr <- err
+			return
+		}
+		events = nil
+	}
+}
+
+// flush stores a batch of events and queues it for every client. On a
+// store error, the events stored before it are still sent.
+func (s *Server) flush(events []models.LogEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	// Update snapshot with the events, which gives them their sequence IDs
+	events, err := s.append(events...)
+	s.rate.add(len(events), time.Now())
+
+	// Events that made room for these take their bookmarks with them
+	s.pruneBookmarks()
+
+	s.mu.RLock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.RUnlock()
+
+	// Queue for all clients whose filter matches; each client's writer
+	// sends it, so a slow client doesn't hold up the others
+	if len(events) > 0 {
+		b := newBatch(events)
+		for _, c := range clients {
+			c.deliver(b)
+		}
+	}
+	return err
+}
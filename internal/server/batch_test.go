@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// benchCollector is a collector with no events of its own
+type benchCollector struct{}
+
+func (benchCollector) Start(chan<- models.LogEvent) error      { return nil }
+func (benchCollector) Stop() error                             { return nil }
+func (benchCollector) GetSnapshot() ([]models.LogEvent, error) { return nil, nil }
+func (benchCollector) GetSourceName() string                   { return "bench" }
+
+// BenchmarkBroadcast measures how many events per second reach every one
+// of several clients, with and without batching and compression.
+func BenchmarkBroadcast(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		batchSize int
+		compress  bool
+	}{
+		{"unbatched", 1, false},
+		{"batched", maxBatchSize, false},
+		{"batched-deflate", maxBatchSize, true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			benchmarkBroadcast(b, 10, bc.batchSize, bc.compress)
+		})
+	}
+}
+
+func benchmarkBroadcast(b *testing.B, clients, batchSize int, compress bool) {
+	// Closing compressed connections logs errors that don't matter here
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	s := NewServer(0, benchCollector{}, Options{QueueSize: 1 << 20})
+	s.batchSize = batchSize
+	go s.broadcast()
+	defer close(s.eventChan)
+
+	ts := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+	defer ts.Close()
+
+	dialer := websocket.Dialer{EnableCompression: compress}
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		conn, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+
+		// Skip the snapshot
+		if _, _, err := conn.ReadMessage(); err != nil {
+			b.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			received := 0
+			for received < b.N {
+				var msg struct {
+					Type   string            `json:"type"`
+					Events []models.LogEvent `json:"events"`
+					Lost   int               `json:"lost"`
+				}
+				if err := conn.ReadJSON(&msg); err != nil {
+					b.Error(err)
+					return
+				}
+				received += len(msg.Events) + msg.Lost
+			}
+		}()
+	}
+
+	const message = "[2026-10-16 10:00:00] production.ERROR: SQLSTATE[HY000] [2002] Connection refused (Connection: mysql)"
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		s.eventChan <- models.LogEvent{
+			Timestamp: time.Now(),
+			Source:    "file",
+			Message:   fmt.Sprintf("%s #%d", message, i),
+		}
+	}
+	wg.Wait()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "events/s")
+}
+
+func TestBatchMatchingRelativeTimes(t *testing.T) {
+	// The same filter subscribed to at different moments covers different
+	// windows, so the two clients can't share a message
+	earlier, err := parseFilter("since:100ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	between := time.Now()
+	time.Sleep(150 * time.Millisecond)
+	later, err := parseFilter("since:100ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newBatch([]models.LogEvent{
+		{Seq: 0, Timestamp: between, Message: "between"},
+		{Seq: 1, Timestamp: time.Now(), Message: "now"},
+	})
+	if m := b.matching(earlier); m == nil || len(m.events) != 2 {
+		t.Fatalf("earlier subscription matched %v", m)
+	}
+	if m := b.matching(later); m == nil || len(m.events) != 1 || m.events[0].Message != "now" {
+		t.Errorf("later subscription matched %v", m)
+	}
+}
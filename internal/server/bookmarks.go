@@ -0,0 +1,348 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// bookmarksFile holds the bookmarks in the store's directory
+	bookmarksFile = "bookmarks.json"
+
+	// maxBookmarks caps how many bookmarks an instance keeps
+	maxBookmarks = 1000
+
+	// maxNoteLength caps a bookmark's note, in characters
+	maxNoteLength = 500
+
+	// maxSessionLength caps a session name, in bytes
+	maxSessionLength = 100
+)
+
+// bookmark marks an event by its sequence ID, with an optional note such
+// as "deploy started here". It belongs to a session: the clients that pass
+// the same session name share its bookmarks; those without one share the
+// default session, "".
+type bookmark struct {
+	ID        string    `json:"id"`
+	Session   string    `json:"session,omitempty"`
+	Seq       int64     `json:"seq"`
+	Note      string    `json:"note,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// bookmarkList is the bookmarks of every session of the server instance.
+// With a store they're saved next to the events, so they last as long as
+// the sequence IDs they refer to; otherwise they're kept in memory.
+type bookmarkList struct {
+	mu    sync.Mutex
+	path  string     // "" without a store
+	marks []bookmark // by sequence ID, then creation
+}
+
+// loadBookmarks reads the bookmarks saved at path; an empty path or a
+// missing file gives none
+func loadBookmarks(path string) (*bookmarkList, error) {
+	l := &bookmarkList{path: path}
+	if path == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.marks); err != nil {
+		return nil, fmt.Errorf("failed to parse bookmarks: %w", err)
+	}
+	return l, nil
+}
+
+// list returns a copy of a session's bookmarks
+func (l *bookmarkList) list(session string) []bookmark {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	marks := []bookmark{}
+	for _, b := range l.marks {
+		if b.Session == session {
+			marks = append(marks, b)
+		}
+	}
+	return marks
+}
+
+// add adds a bookmark, giving it an ID and creation time
+func (l *bookmarkList) add(b bookmark) (bookmark, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.marks) >= maxBookmarks {
+		return bookmark{}, fmt.Errorf("too many bookmarks (at most %d)", maxBookmarks)
+	}
+
+	b.ID = newInstanceID()
+	b.CreatedAt = time.Now()
+	i := sort.Search(len(l.marks), func(i int) bool {
+		return l.marks[i].Seq > b.Seq
+	})
+	l.marks = append(l.marks[:i], append([]bookmark{b}, l.marks[i:]...)...)
+	return b, l.save()
+}
+
+// update changes the note of a session's bookmark; it reports false for an
+// unknown ID
+func (l *bookmarkList) update(session, id, note string) (bookmark, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range l.marks {
+		if l.marks[i].ID == id && l.marks[i].Session == session {
+			l.marks[i].Note = note
+			return l.marks[i], true, l.save()
+		}
+	}
+	return bookmark{}, false, nil
+}
+
+// remove deletes a session's bookmark; it reports false for an unknown ID
+func (l *bookmarkList) remove(session, id string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range l.marks {
+		if l.marks[i].ID == id && l.marks[i].Session == session {
+			l.marks = append(l.marks[:i], l.marks[i+1:]...)
+			return true, l.save()
+		}
+	}
+	return false, nil
+}
+
+// prune deletes the bookmarks of events before first, which are no longer
+// retained, and returns the sessions that lost any
+func (l *bookmarkList) prune(first int64) (map[string]bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Sorted by sequence ID, so the pruned ones come first
+	i := 0
+	for i < len(l.marks) && l.marks[i].Seq < first {
+		i++
+	}
+	if i == 0 {
+		return nil, nil
+	}
+
+	sessions := make(map[string]bool)
+	for _, b := range l.marks[:i] {
+		sessions[b.Session] = true
+	}
+	l.marks = append([]bookmark(nil), l.marks[i:]...)
+	return sessions, l.save()
+}
+
+// save writes the bookmarks to their file, replacing it whole so a crash
+// can't leave it half written; the caller holds l.mu
+func (l *bookmarkList) save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(l.marks)
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save bookmarks: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to save bookmarks: %w", err)
+	}
+	return nil
+}
+
+// bookmarksPath returns where the server's bookmarks are saved: in the
+// store's directory, or nowhere without a store
+func (s *Server) bookmarksPath() string {
+	if s.history == nil {
+		return ""
+	}
+	return filepath.Join(s.history.Dir(), bookmarksFile)
+}
+
+// bookmarkRequest is the body of a POST or PATCH to /api/bookmarks
+type bookmarkRequest struct {
+	Seq    *int64 `json:"seq"`
+	Note   string `json:"note"`
+	Author string `json:"author"`
+}
+
+// handleBookmarks lists a session's bookmarks (GET), adds one to an
+// event (POST {"seq":42,"note":"deploy started here"}), changes a note
+// (PATCH ?id=... {"note":"..."}) or removes one (DELETE ?id=...). The
+// session is the session parameter, or the default session without one.
+// Every change is sent to the WebSocket clients of the session, so
+// teammates sharing it see the same marks. Changes must come from a
+// localhost page, and POST and PATCH bodies must be JSON, so other sites
+// can't send them from the browser.
+func (s *Server) handleBookmarks(w http.ResponseWriter, r *http.Request) {
+	session, ok := parseSession(w, r.URL.Query().Get("session"))
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet && !localOrigin(r) {
+		http.Error(w, "bookmarks can only be changed from localhost", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"instance":  s.instance,
+			"session":   session,
+			"bookmarks": s.bookmarks.list(session),
+		})
+	case http.MethodPost:
+		req, ok := decodeBookmarkRequest(w, r)
+		if !ok {
+			return
+		}
+		first, end := s.historyRange()
+		if req.Seq == nil || *req.Seq < first || *req.Seq >= end {
+			http.Error(w, fmt.Sprintf("seq must be the sequence ID of a retained event (%d to %d)", first, end-1), http.StatusBadRequest)
+			return
+		}
+
+		b, err := s.bookmarks.add(bookmark{Session: session, Seq: *req.Seq, Note: req.Note, Author: req.Author})
+		if err != nil {
+			log.Printf("Error adding bookmark: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.sendBookmarks(session)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(b)
+	case http.MethodPatch:
+		req, ok := decodeBookmarkRequest(w, r)
+		if !ok {
+			return
+		}
+		b, found, err := s.bookmarks.update(session, r.URL.Query().Get("id"), req.Note)
+		if !found {
+			http.Error(w, "bookmark not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error updating bookmark: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.sendBookmarks(session)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(b)
+	case http.MethodDelete:
+		found, err := s.bookmarks.remove(session, r.URL.Query().Get("id"))
+		if !found {
+			http.Error(w, "bookmark not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error removing bookmark: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.sendBookmarks(session)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, PATCH, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeBookmarkRequest reads and checks a bookmark request body,
+// reporting any problem to the client
+func decodeBookmarkRequest(w http.ResponseWriter, r *http.Request) (bookmarkRequest, bool) {
+	var req bookmarkRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "bookmarks must be sent as application/json", http.StatusUnsupportedMediaType)
+		return req, false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "invalid bookmark: "+err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+
+	req.Note = strings.TrimSpace(req.Note)
+	req.Author = strings.TrimSpace(req.Author)
+	if utf8.RuneCountInString(req.Note) > maxNoteLength || utf8.RuneCountInString(req.Author) > maxNoteLength {
+		http.Error(w, fmt.Sprintf("note and author are limited to %d characters", maxNoteLength), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// parseSession checks a session name, reporting a bad one to the client
+func parseSession(w http.ResponseWriter, session string) (string, bool) {
+	if len(session) > maxSessionLength || !utf8.ValidString(session) {
+		http.Error(w, fmt.Sprintf("session names are limited to %d bytes of UTF-8", maxSessionLength), http.StatusBadRequest)
+		return "", false
+	}
+	return session, true
+}
+
+// bookmarksMessage is the "bookmarks" message with every bookmark of a
+// session
+func (s *Server) bookmarksMessage(session string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "bookmarks",
+		"bookmarks": s.bookmarks.list(session),
+	}
+}
+
+// sendBookmarks queues a session's bookmarks for its clients after a change
+func (s *Server) sendBookmarks(session string) {
+	message := s.bookmarksMessage(session)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for c := range s.clients {
+		if c.session == session {
+			c.mu.Lock()
+			c.enqueue(outgoing{message: message})
+			c.mu.Unlock()
+		}
+	}
+}
+
+// pruneBookmarks deletes the bookmarks of events no longer retained and
+// tells the clients of the sessions that lost any
+func (s *Server) pruneBookmarks() {
+	first, _ := s.historyRange()
+	sessions, err := s.bookmarks.prune(first)
+	if err != nil {
+		log.Printf("Error pruning bookmarks: %v", err)
+	}
+	for session := range sessions {
+		s.sendBookmarks(session)
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// newBookmarkServer returns a server with ten events to bookmark
+func newBookmarkServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer(0, benchCollector{}, Options{})
+	if _, err := s.append(make([]models.LogEvent, 10)...); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// serveBookmarks sends a request to /api/bookmarks from the dashboard
+func serveBookmarks(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Host = "127.0.0.1:4040"
+	r.Header.Set("Origin", "http://127.0.0.1:4040")
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	s.handleBookmarks(w, r)
+	return w
+}
+
+func TestBookmarkRequestChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{"dashboard", http.MethodPost, "http://127.0.0.1:4040", "application/json", http.StatusCreated},
+		{"localhost name", http.MethodPost, "http://localhost:4040", "application/json; charset=utf-8", http.StatusCreated},
+		{"no origin", http.MethodPost, "", "application/json", http.StatusCreated},
+		{"other site", http.MethodPost, "https://example.com", "application/json", http.StatusForbidden},
+		{"other site, form", http.MethodPost, "https://example.com", "text/plain", http.StatusForbidden},
+		{"opaque origin", http.MethodPost, "null", "application/json", http.StatusForbidden},
+		{"text body", http.MethodPost, "", "text/plain", http.StatusUnsupportedMediaType},
+		{"no content type", http.MethodPost, "", "", http.StatusUnsupportedMediaType},
+		{"other site patch", http.MethodPatch, "https://example.com", "application/json", http.StatusForbidden},
+		{"text patch", http.MethodPatch, "", "text/plain", http.StatusUnsupportedMediaType},
+		{"other site delete", http.MethodDelete, "https://example.com", "", http.StatusForbidden},
+		{"other site list", http.MethodGet, "https://example.com", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newBookmarkServer(t)
+			b, err := s.bookmarks.add(bookmark{Seq: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target, body := "/api/bookmarks", `{"seq": 2, "note": "deploy"}`
+			if tt.method == http.MethodPatch || tt.method == http.MethodDelete {
+				target += "?id=" + b.ID
+				body = `{"note": "deploy"}`
+			}
+			r := httptest.NewRequest(tt.method, target, strings.NewReader(body))
+			r.Host = "127.0.0.1:4040"
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			s.handleBookmarks(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			// Refused changes leave the bookmarks as they were
+			if w.Code >= 400 {
+				if marks := s.bookmarks.list(""); len(marks) != 1 || marks[0].Note != "" {
+					t.Errorf("bookmarks changed to %+v", marks)
+				}
+			}
+		})
+	}
+}
+
+func TestBookmarkSessions(t *testing.T) {
+	s := newBookmarkServer(t)
+	clients := map[string]*client{}
+	for _, session := range []string{"", "a", "b"} {
+		clients[session] = s.newClient(newTestConn(), "websocket", "test", session)
+		s.addClient(clients[session])
+	}
+
+	if w := serveBookmarks(s, http.MethodPost, "/api/bookmarks?session=a", `{"seq": 3}`); w.Code != http.StatusCreated {
+		t.Fatalf("adding to a: %d %s", w.Code, w.Body)
+	}
+	if w := serveBookmarks(s, http.MethodPost, "/api/bookmarks", `{"seq": 4}`); w.Code != http.StatusCreated {
+		t.Fatalf("adding to the default session: %d %s", w.Code, w.Body)
+	}
+
+	// Each session lists only its own
+	for session, want := range map[string][]int64{"": {4}, "a": {3}, "b": {}} {
+		w := serveBookmarks(s, http.MethodGet, "/api/bookmarks?session="+session, "")
+		var resp struct{ Bookmarks []bookmark }
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		var got []int64
+		for _, b := range resp.Bookmarks {
+			got = append(got, b.Seq)
+		}
+		if len(got) != len(want) || (len(want) > 0 && got[0] != want[0]) {
+			t.Errorf("session %q lists %v, want %v", session, got, want)
+		}
+	}
+
+	// Changes go only to the clients of the session
+	for session, want := range map[string]int{"": 1, "a": 1, "b": 0} {
+		c := clients[session]
+		c.mu.Lock()
+		if len(c.queue) != want {
+			t.Errorf("session %q was sent %d messages, want %d", session, len(c.queue), want)
+		}
+		c.mu.Unlock()
+	}
+
+	// Another session's bookmark can't be changed or removed
+	id := s.bookmarks.list("a")[0].ID
+	if w := serveBookmarks(s, http.MethodDelete, "/api/bookmarks?session=b&id="+id, ""); w.Code != http.StatusNotFound {
+		t.Errorf("removing a's bookmark from b: %d", w.Code)
+	}
+	if w := serveBookmarks(s, http.MethodGet, "/api/bookmarks?session="+strings.Repeat("x", maxSessionLength+1), ""); w.Code != http.StatusBadRequest {
+		t.Errorf("long session name: %d", w.Code)
+	}
+}
+
+func TestPruneBookmarks(t *testing.T) {
+	tests := []struct {
+		name     string
+		first    int64
+		want     []int64
+		sessions []string
+	}{
+		{"all retained", 2, []int64{2, 5, 5, 9}, nil},
+		{"some dropped", 5, []int64{5, 5, 9}, []string{"a"}},
+		{"all dropped", 10, nil, []string{"", "a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &bookmarkList{}
+			for _, b := range []bookmark{{Seq: 9}, {Session: "a", Seq: 2}, {Session: "a", Seq: 5}, {Session: "b", Seq: 5}} {
+				if _, err := l.add(b); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sessions, err := l.prune(tt.first)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, b := range l.marks {
+				got = append(got, b.Seq)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("left %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("left %v, want %v", got, tt.want)
+				}
+			}
+			if len(sessions) != len(tt.sessions) {
+				t.Errorf("pruned from %v, want %v", sessions, tt.sessions)
+			}
+			for _, session := range tt.sessions {
+				if !sessions[session] {
+					t.Errorf("pruned from %v, want %v", sessions, tt.sessions)
+				}
+			}
+		})
+	}
+}
+
+func TestFlushPrunesBookmarks(t *testing.T) {
+	s := NewServer(0, benchCollector{}, Options{})
+	if err := s.flush(make([]models.LogEvent, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.bookmarks.add(bookmark{Session: "a", Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	c := s.newClient(newTestConn(), "websocket", "test", "a")
+	s.addClient(c)
+
+	// Enough events to push the bookmarked one out of memory
+	if err := s.flush(make([]models.LogEvent, maxSnapshotSize)); err != nil {
+		t.Fatal(err)
+	}
+	if marks := s.bookmarks.list("a"); len(marks) != 0 {
+		t.Errorf("bookmarks of dropped events are left: %+v", marks)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found := false
+	for _, item := range c.queue {
+		if m, ok := item.message.(map[string]interface{}); ok && m["type"] == "bookmarks" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("the session wasn't sent its pruned bookmarks")
+	}
+}
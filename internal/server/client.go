@@ -0,0 +1,650 @@
+package server
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+const (
+	// writeWait is how long a single write to a client may take
+	writeWait = 10 * time.Second
+
+	// pingPeriod is how often clients are pinged to keep connections alive
+	pingPeriod = 54 * time.Second
+
+	// DefaultQueueSize is how many live events may wait to be written to a
+	// client before the overflow policy applies
+	DefaultQueueSize = 1024
+)
+
+// Overflow policies for a client whose queue is full
+const (
+	// OverflowDrop drops the oldest queued events and sends a gap message
+	// in their place
+	OverflowDrop = "drop"
+
+	// OverflowDisconnect closes the connection; the client can resume
+	OverflowDisconnect = "disconnect"
+)
+
+// client is a connection receiving events and the filter it subscribed
+// with. Messages are queued and written by the client's own writer
+// goroutine, so a slow client only ever holds up itself.
+type client struct {
+	conn      clientConn
+	transport string // "websocket", or the /api/stream format
+	remote    string
+	session   string // whose bookmarks the client gets
+	connected time.Time
+
+	queueSize int
+	overflow  string
+	metrics   *queueMetrics
+
+	// mu guards the fields below
+	mu sync.Mutex
+
+	filter *query.Query // nil delivers every event
+
+	// next is the sequence ID of the first event not covered by the last
+	// snapshot sent, so live events already in it aren't sent twice
+	next int64
+
+	queue        []outgoing
+	queuedEvents int // live events in queue, which count towards queueSize
+	maxQueued    int // most live events queued at once
+	sent         int64
+	dropped      int64
+
+	// While paused, live events and gaps are held in queue rather than
+	// written, and summed up in held for when the client unpauses
+	paused bool
+	held   pauseSummary
+
+	wake      chan struct{} // signals the writer that the queue has items
+	done      chan struct{} // closed when the client is closed
+	closeOnce sync.Once
+}
+
+// clientConn is the connection a client's messages are written to
+type clientConn interface {
+	write(item outgoing) error
+	ping() error
+	close() error
+}
+
+// outgoing is a queued message. Batches of live events can be dropped on
+// overflow; other messages are always written.
+type outgoing struct {
+	message interface{}
+
+	// events are the events carried by message, which is all a stream
+	// writes of it
+	events []models.LogEvent
+
+	gap    *gap           // set for gap messages
+	live   *eventsMessage // set for a batch of live events
+	replay *replay        // set for the events a resuming client missed
+}
+
+// gap is a run of events a client didn't receive
+type gap struct {
+	from, to int64 // sequence IDs in [from, to)
+	lost     int64 // how many of them were for the client
+
+	// unfiltered is set when lost counts every event of the run, because
+	// they were deleted before they could be matched against the client's
+	// filter
+	unfiltered bool
+}
+
+// retentionGap is the gap of the events in [from, to), which are no longer
+// retained
+func retentionGap(from, to int64, filter *query.Query) *gap {
+	return &gap{from: from, to: to, lost: to - from, unfiltered: filter != nil}
+}
+
+func (g *gap) message() map[string]interface{} {
+	message := map[string]interface{}{
+		"type": "gap",
+		"from": g.from,
+		"to":   g.to,
+		"lost": g.lost,
+	}
+	if g.unfiltered {
+		message["unfiltered"] = true
+	}
+	return message
+}
+
+// replay is the run of events [from, to) a resuming client missed. The
+// client's writer reads and writes it a chunk at a time, reading the next
+// chunk only once the last one was written, so a long replay is never all
+// in memory.
+type replay struct {
+	from, to int64
+	filter   *query.Query
+	read     func(from, to int64) ([]models.LogEvent, int64, error)
+}
+
+// chunk returns the messages for the next chunk of the replay: a gap for
+// events deleted by retention, and a "replay" message of the events
+// matching the filter. It returns none when the replay is done.
+func (r *replay) chunk() ([]outgoing, error) {
+	for r.from < r.to {
+		events, start, err := r.read(r.from, min(r.to, r.from+maxHistoryLimit))
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			// Deleted by retention while replaying
+			start = r.to
+		}
+
+		var items []outgoing
+		if start > r.from {
+			items = append(items, outgoing{gap: retentionGap(r.from, start, r.filter)})
+		}
+
+		matched := make([]models.LogEvent, 0, len(events))
+		for _, event := range events {
+			if r.filter == nil || r.filter.Match(event) {
+				matched = append(matched, event)
+			}
+		}
+		if len(matched) > 0 {
+			items = append(items, outgoing{
+				message: map[string]interface{}{
+					"type":   "replay",
+					"events": matched,
+				},
+				events: matched,
+			})
+		}
+
+		r.from = start + int64(len(events))
+		if len(items) > 0 {
+			return items, nil
+		}
+	}
+	return nil, nil
+}
+
+// queueMetrics counts queue overflows across all clients
+type queueMetrics struct {
+	dropped      atomic.Int64 // events dropped from full queues
+	disconnected atomic.Int64 // clients disconnected for a full queue
+}
+
+// clientMessage is a message sent by a client over /ws
+type clientMessage struct {
+	Type string `json:"type"`
+
+	// Filter is a search query (see the query package); empty for all events
+	Filter string `json:"filter"`
+
+	// Seq and Instance are the last sequence ID a resuming client saw and
+	// the server instance it came from
+	Seq      *int64 `json:"seq"`
+	Instance string `json:"instance"`
+}
+
+// Client message types
+const (
+	messageSubscribe = "subscribe"
+	messageResume    = "resume"
+	messagePause     = "pause"
+	messageUnpause   = "unpause"
+)
+
+// pauseSummary sums up the live events that arrived while a client was
+// paused
+type pauseSummary struct {
+	since    time.Time
+	from, to int64 // sequence IDs in [from, to); both 0 when count is 0
+	count    int64
+	dropped  int64 // how many of them were dropped on overflow
+	levels   map[string]int64
+}
+
+func (p *pauseSummary) add(events []models.LogEvent) {
+	if p.count == 0 {
+		p.from = events[0].Seq
+	}
+	p.to = events[len(events)-1].Seq + 1
+	p.count += int64(len(events))
+	for _, event := range events {
+		p.levels[query.Level(event.Level, event.Message)]++
+	}
+}
+
+func (p *pauseSummary) message() map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "summary",
+		"since":   p.since,
+		"from":    p.from,
+		"to":      p.to,
+		"count":   p.count,
+		"dropped": p.dropped,
+		"levels":  p.levels,
+	}
+}
+
+func (s *Server) newClient(conn clientConn, transport, remote, session string) *client {
+	return &client{
+		conn:      conn,
+		transport: transport,
+		remote:    remote,
+		session:   session,
+		connected: time.Now(),
+		queueSize: s.queueSize,
+		overflow:  s.overflow,
+		metrics:   &s.queueMetrics,
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// wsConn writes a client's messages to a WebSocket
+type wsConn struct {
+	*websocket.Conn
+}
+
+func (c wsConn) write(item outgoing) error {
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	switch {
+	case item.live != nil:
+		pm, err := item.live.encode()
+		if err != nil {
+			log.Printf("Error encoding events: %v", err)
+			return nil
+		}
+		return c.WritePreparedMessage(pm)
+	case item.gap != nil:
+		return c.WriteJSON(item.gap.message())
+	default:
+		return c.WriteJSON(item.message)
+	}
+}
+
+func (c wsConn) ping() error {
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (c wsConn) close() error {
+	return c.Conn.Close()
+}
+
+// enqueue queues a message for the writer; the caller holds c.mu
+func (c *client) enqueue(item outgoing) {
+	c.queue = append(c.queue, item)
+	if item.live != nil {
+		c.queuedEvents += len(item.live.events)
+		c.maxQueued = max(c.maxQueued, c.queuedEvents)
+	}
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sendError queues an error message for a request the client made
+func (c *client) sendError(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.enqueue(outgoing{message: map[string]interface{}{
+		"type":  "error",
+		"error": text,
+	}})
+}
+
+// deliver queues the events of a batch that pass the client's filter and
+// weren't already part of its snapshot. When the queue is full, the oldest
+// queued batches are dropped or the client disconnected, according to the
+// policy; a paused client's oldest batches are always dropped.
+func (c *client) deliver(b *batch) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var m *eventsMessage
+	switch {
+	case b.events[0].Seq >= c.next && c.filter == nil:
+		m = b.allEvents()
+	case b.events[0].Seq >= c.next:
+		m = b.matching(c.filter)
+	default:
+		// Part of the batch was in the client's snapshot
+		var events []models.LogEvent
+		for _, event := range b.events {
+			if event.Seq >= c.next && (c.filter == nil || c.filter.Match(event)) {
+				events = append(events, event)
+			}
+		}
+		if len(events) > 0 {
+			m = &eventsMessage{events: events}
+		}
+	}
+	if m == nil {
+		return
+	}
+
+	if c.paused {
+		c.held.add(m.events)
+	}
+
+	for c.queuedEvents > 0 && c.queuedEvents+len(m.events) > c.queueSize {
+		if c.overflow == OverflowDisconnect && !c.paused {
+			c.metrics.disconnected.Add(1)
+			c.close()
+			return
+		}
+		c.dropOldest()
+	}
+
+	c.enqueue(outgoing{live: m})
+}
+
+// dropOldest replaces the oldest queued batch with a gap, merging it into
+// a gap of dropped batches right before it; the caller holds c.mu. Only
+// events that passed the filter were queued, so only they count as lost.
+func (c *client) dropOldest() {
+	for i, item := range c.queue {
+		if item.live == nil {
+			continue
+		}
+
+		events := item.live.events
+		from, to := events[0].Seq, events[len(events)-1].Seq+1
+		if i > 0 && c.queue[i-1].gap != nil && !c.queue[i-1].gap.unfiltered {
+			g := c.queue[i-1].gap
+			g.to = to
+			g.lost += int64(len(events))
+			c.queue = append(c.queue[:i], c.queue[i+1:]...)
+		} else {
+			c.queue[i] = outgoing{gap: &gap{from: from, to: to, lost: int64(len(events))}}
+		}
+
+		c.queuedEvents -= len(events)
+		c.dropped += int64(len(events))
+		if c.paused {
+			c.held.dropped += int64(len(events))
+		}
+		c.metrics.dropped.Add(int64(len(events)))
+		return
+	}
+}
+
+// clearEvents drops the queued live events, which a new snapshot covers,
+// along with what a paused client was holding; the caller holds c.mu
+func (c *client) clearEvents() {
+	kept := c.queue[:0]
+	for _, item := range c.queue {
+		if item.live == nil && item.gap == nil {
+			kept = append(kept, item)
+		}
+	}
+	clear(c.queue[len(kept):])
+	c.queue = kept
+	c.queuedEvents = 0
+	if c.paused {
+		c.held = newPauseSummary()
+	}
+}
+
+func newPauseSummary() pauseSummary {
+	return pauseSummary{since: time.Now(), levels: make(map[string]int64)}
+}
+
+// pause holds the client's live events until it unpauses
+func (c *client) pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.paused {
+		c.paused = true
+		c.held = newPauseSummary()
+	}
+	c.enqueue(outgoing{message: map[string]interface{}{
+		"type":  "paused",
+		"since": c.held.since,
+	}})
+}
+
+// unpause writes the held live events, preceded by a summary of them
+func (c *client) unpause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.paused {
+		return
+	}
+	c.paused = false
+
+	i := 0
+	for i < len(c.queue) && c.queue[i].live == nil && c.queue[i].gap == nil {
+		i++
+	}
+	c.queue = slices.Insert(c.queue, i, outgoing{message: c.held.message()})
+	c.held = pauseSummary{}
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the next queued message. A paused client only gets messages
+// other than live events and gaps, which stay queued.
+func (c *client) pop() (outgoing, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := 0
+	if c.paused {
+		for i < len(c.queue) && (c.queue[i].live != nil || c.queue[i].gap != nil) {
+			i++
+		}
+	}
+	if i == len(c.queue) {
+		return outgoing{}, false
+	}
+
+	item := c.queue[i]
+	if i == 0 {
+		c.queue[0] = outgoing{}
+		c.queue = c.queue[1:]
+	} else {
+		c.queue = slices.Delete(c.queue, i, i+1)
+	}
+	if item.live != nil {
+		c.queuedEvents -= len(item.live.events)
+		c.sent += int64(len(item.live.events))
+	}
+	return item, true
+}
+
+// writeLoop writes queued messages and pings until the client is closed
+// or a write fails
+func (c *client) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	defer c.close()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.conn.ping(); err != nil {
+				return
+			}
+		case <-c.wake:
+			for {
+				item, ok := c.pop()
+				if !ok {
+					break
+				}
+				if err := c.write(item); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// write writes a queued message, or a replay chunk by chunk
+func (c *client) write(item outgoing) error {
+	if item.replay == nil {
+		return c.conn.write(item)
+	}
+
+	for {
+		items, err := item.replay.chunk()
+		if err != nil {
+			log.Printf("Error replaying events: %v", err)
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for _, item := range items {
+			if err := c.conn.write(item); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// close stops the writer and closes the connection, which ends the
+// client's read loop or stream
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.close()
+	})
+}
+
+// clientStats describes a client's queue for /api/clients
+type clientStats struct {
+	Transport   string    `json:"transport"`
+	Remote      string    `json:"remote"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	Filter      string    `json:"filter,omitempty"`
+	Queued      int       `json:"queued"`    // live events waiting to be written
+	MaxQueued   int       `json:"maxQueued"` // most live events queued at once
+	Sent        int64     `json:"sent"`      // live events written
+	Dropped     int64     `json:"dropped"`   // live events dropped on overflow
+	Paused      bool      `json:"paused"`    // holding live events until unpaused
+}
+
+func (c *client) stats() clientStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := clientStats{
+		Transport:   c.transport,
+		Remote:      c.remote,
+		ConnectedAt: c.connected,
+		Queued:      c.queuedEvents,
+		MaxQueued:   c.maxQueued,
+		Sent:        c.sent,
+		Dropped:     c.dropped,
+		Paused:      c.paused,
+	}
+	if c.filter != nil {
+		stats.Filter = c.filter.String()
+	}
+	return stats
+}
+
+// subscribe sets the client's filter and sends it the matching snapshot
+func (s *Server) subscribe(c *client, filter *query.Query) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.filter = filter
+	s.sendSnapshot(c)
+}
+
+// sendSnapshot queues the snapshot events matching the client's filter in
+// place of any queued live events; the caller holds c.mu
+func (s *Server) sendSnapshot(c *client) {
+	filter := c.filter
+
+	s.snapshotMu.RLock()
+	events := make([]models.LogEvent, 0, len(s.snapshot))
+	for _, event := range s.snapshot {
+		if filter == nil || filter.Match(event) {
+			events = append(events, event)
+		}
+	}
+	c.next = s.snapshotBase + int64(len(s.snapshot))
+	s.snapshotMu.RUnlock()
+
+	message := map[string]interface{}{
+		"type":       "snapshot",
+		"events":     events,
+		"sourceName": s.collector.GetSourceName(),
+		// Cursor for /api/events to load events older than the snapshot
+		"first": s.snapshotBase,
+		// Passed back when resuming
+		"instance":  s.instance,
+		"bookmarks": s.bookmarks.list(c.session),
+		"statuses":  s.statuses.list(),
+	}
+	if filter != nil {
+		message["filter"] = filter.String()
+	}
+
+	c.clearEvents()
+	c.enqueue(outgoing{message: message, events: events})
+}
+
+// resume sets the client's filter and replays the events after seq that it
+// missed while disconnected, instead of sending a new snapshot. Events that
+// are no longer retained are reported with a gap message. A client whose
+// sequence IDs came from another server instance gets a snapshot.
+func (s *Server) resume(c *client, filter *query.Query, seq int64, instance string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.filter = filter
+
+	first, end := s.historyRange()
+	if instance != s.instance || seq >= end {
+		s.sendSnapshot(c)
+		return
+	}
+
+	// The replay covers any live events still queued
+	c.clearEvents()
+
+	from := seq + 1
+	if from < first {
+		c.enqueue(outgoing{gap: retentionGap(from, first, filter)})
+		from = first
+	}
+
+	// Read by the writer as it goes; live events queue up behind it
+	c.enqueue(outgoing{replay: &replay{from: from, to: end, filter: filter, read: s.readHistory}})
+	c.next = end
+
+	c.enqueue(outgoing{message: map[string]interface{}{
+		"type":     "resumed",
+		"seq":      end - 1,
+		"instance": s.instance,
+		// Any changed while disconnected
+		"bookmarks": s.bookmarks.list(c.session),
+		"statuses":  s.statuses.list(),
+	}})
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+	"github.com/monstarlab/shepai/internal/store"
+)
+
+// testConn is a client connection that hands each written item to the test,
+// blocking until it's taken
+type testConn struct {
+	items  chan outgoing
+	closed chan struct{}
+}
+
+func newTestConn() *testConn {
+	return &testConn{items: make(chan outgoing), closed: make(chan struct{})}
+}
+
+func (c *testConn) write(item outgoing) error {
+	select {
+	case c.items <- item:
+		return nil
+	case <-c.closed:
+		return fmt.Errorf("closed")
+	}
+}
+
+func (c *testConn) ping() error { return nil }
+
+func (c *testConn) close() error {
+	close(c.closed)
+	return nil
+}
+
+func (c *testConn) next(t *testing.T) outgoing {
+	t.Helper()
+	select {
+	case item := <-c.items:
+		return item
+	case <-time.After(time.Second):
+		t.Fatal("nothing was written")
+		return outgoing{}
+	}
+}
+
+func TestResumeStreamsReplay(t *testing.T) {
+	history, err := store.Open(store.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	s := NewServer(0, benchCollector{}, Options{Store: history})
+
+	events := make([]models.LogEvent, 2500)
+	for i := range events {
+		events[i] = models.LogEvent{Source: "file", Message: fmt.Sprintf("event %d", i)}
+	}
+	if _, err := s.append(events...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Count the chunks read from the store
+	var reads atomic.Int32
+	conn := newTestConn()
+	c := s.newClient(conn, "websocket", "test", "")
+	s.addClient(c)
+	s.resume(c, nil, 99, s.instance)
+	c.mu.Lock()
+	r := c.queue[0].replay
+	read := r.read
+	r.read = func(from, to int64) ([]models.LogEvent, int64, error) {
+		reads.Add(1)
+		return read(from, to)
+	}
+	c.mu.Unlock()
+
+	go c.writeLoop()
+	defer c.close()
+
+	// A chunk is read only once the one before it was written: while the
+	// writer waits to write one, no more are read
+	var replayed []models.LogEvent
+	for chunk := 1; chunk <= 3; chunk++ {
+		item := conn.next(t)
+		replayed = append(replayed, item.events...)
+
+		time.Sleep(20 * time.Millisecond)
+		if got, want := reads.Load(), int32(min(chunk+1, 3)); got != want {
+			t.Fatalf("%d chunks read after writing %d, want %d", got, chunk, want)
+		}
+
+		// Live events queue up behind the replay meanwhile
+		if chunk == 1 {
+			s.flush([]models.LogEvent{{Message: "live"}})
+		}
+	}
+	if len(replayed) != 2400 || replayed[0].Seq != 100 || replayed[2399].Seq != 2499 {
+		t.Fatalf("replayed %d events from %d", len(replayed), replayed[0].Seq)
+	}
+
+	if item := conn.next(t); item.message.(map[string]interface{})["type"] != "resumed" {
+		t.Errorf("after the replay: %v", item.message)
+	}
+	if item := conn.next(t); item.live == nil || item.live.events[0].Seq != 2500 {
+		t.Errorf("after resuming: %+v", item)
+	}
+}
+
+// alternateEvents returns n events, every other one with "alpha" in its
+// message and the rest with "beta"
+func alternateEvents(n int) []models.LogEvent {
+	events := make([]models.LogEvent, n)
+	for i := range events {
+		word := "beta"
+		if i%2 == 0 {
+			word = "alpha"
+		}
+		events[i] = models.LogEvent{Message: fmt.Sprintf("event %d %s", i, word)}
+	}
+	return events
+}
+
+func TestGapLostCounts(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     string
+		overflow   bool // a gap of dropped batches rather than deleted events
+		lost       int64
+		unfiltered bool
+	}{
+		{"deleted", "", false, 489, false},
+		{"deleted filtered", "alpha", false, 489, true},
+		{"dropped", "", true, 30, false},
+		{"dropped filtered", "alpha", true, 15, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(0, benchCollector{}, Options{QueueSize: 25})
+			var filter *query.Query
+			if tt.filter != "" {
+				var err error
+				if filter, err = query.Parse(tt.filter); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c := s.newClient(newTestConn(), "websocket", "test", "")
+
+			if tt.overflow {
+				// Two batches of 30, or of the 15 matching the filter, don't
+				// fit a queue of 25, so the first is dropped
+				s.subscribe(c, filter)
+				events, err := s.append(alternateEvents(60)...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				c.deliver(newBatch(events[:30]))
+				c.deliver(newBatch(events[30:]))
+			} else {
+				// The first 500 of 1500 events are no longer kept in memory
+				if _, err := s.append(alternateEvents(1500)...); err != nil {
+					t.Fatal(err)
+				}
+				s.resume(c, filter, 10, s.instance)
+			}
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			var g *gap
+			for _, item := range c.queue {
+				if item.gap != nil {
+					g = item.gap
+					break
+				}
+			}
+			if g == nil {
+				t.Fatal("no gap was queued")
+			}
+			if g.lost != tt.lost || g.unfiltered != tt.unfiltered {
+				t.Errorf("lost %d, unfiltered %v; want %d, %v", g.lost, g.unfiltered, tt.lost, tt.unfiltered)
+			}
+			if _, ok := g.message()["unfiltered"]; ok != tt.unfiltered {
+				t.Errorf("message %v", g.message())
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+// maxCorrelated is how many of the newest events carrying an ID are returned
+const maxCorrelated = maxSnapshotSize
+
+// handleCorrelate returns the retained events carrying a trace or request
+// ID: those in the store when one is configured, or in memory otherwise
+func (s *Server) handleCorrelate(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "missing id parameter", http.StatusBadRequest)
+		return
+	}
+
+	first, end := s.historyRange()
+	positions := clipPositions(s.index.Term(query.IDTerm(id)), first, end)
+	truncated := len(positions) > maxCorrelated
+	if truncated {
+		positions = positions[len(positions)-maxCorrelated:]
+	}
+
+	events, err := s.readPositions(positions)
+	if err != nil {
+		http.Error(w, "failed to read events: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []models.LogEvent{}
+	}
+
+	resp := map[string]interface{}{
+		"id":     id,
+		"events": events,
+	}
+	if truncated {
+		resp["truncated"] = true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
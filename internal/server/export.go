@@ -0,0 +1,224 @@
+package server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+// Formats of /api/export
+const (
+	exportNDJSON = "ndjson"
+	exportCSV    = "csv"
+	exportText   = "txt"
+)
+
+// exportContentTypes are the content types of the export formats
+var exportContentTypes = map[string]string{
+	exportNDJSON: "application/x-ndjson",
+	exportCSV:    "text/csv; charset=utf-8",
+	exportText:   "text/plain; charset=utf-8",
+}
+
+// csvHeader names the columns of a CSV export. Fields are a JSON object, as
+// each event may have different ones.
+var csvHeader = []string{"seq", "timestamp", "level", "source", "stream", "message", "details", "fields"}
+
+// handleExport streams every retained event matching a query, oldest first,
+// as a download. Events are exported as stored, so redacted values stay
+// hidden; their level is the one the dashboard shows.
+// Parameters: format (ndjson, csv or txt), query, and from and to (times as
+// in queries, e.g. 2026-10-16T10:00:00Z or 15m; to is exclusive).
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	format := params.Get("format")
+	if format == "" {
+		format = exportNDJSON
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid format %q: use %q, %q or %q", format, exportNDJSON, exportCSV, exportText), http.StatusBadRequest)
+		return
+	}
+
+	q, err := query.Parse(params.Get("query"))
+	if err != nil {
+		http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	from, err := exportTime(params.Get("from"), now)
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := exportTime(params.Get("to"), now)
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Extend the server's write timeout, which large exports outlast
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="shepai-%s.%s"`, now.Format("20060102-150405"), format))
+
+	ew := newExportWriter(w, format)
+	inRange := func(event models.LogEvent) bool {
+		return (from.IsZero() || !event.Timestamp.Before(from)) &&
+			(to.IsZero() || event.Timestamp.Before(to))
+	}
+	err = s.exportEvents(q, func(event models.LogEvent) error {
+		if !inRange(event) {
+			return nil
+		}
+		return ew.write(event)
+	})
+	if err == nil {
+		err = ew.flush()
+	}
+	if err != nil {
+		// The status was sent with the first events, so the download just
+		// ends early
+		log.Printf("Error exporting events: %v", err)
+	}
+}
+
+// exportTime parses a from or to time; empty means no bound
+func exportTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return query.ParseTime(value, now.UTC())
+}
+
+// exportEvents calls fn with every retained event matching q, oldest first
+func (s *Server) exportEvents(q *query.Query, fn func(models.LogEvent) error) error {
+	first, end := s.historyRange()
+
+	positions, exact, ok := q.Candidates(s.index)
+	if !ok {
+		for from := first; from < end; {
+			events, start, err := s.readHistory(from, min(end, from+maxHistoryLimit))
+			if err != nil {
+				return err
+			}
+			if len(events) == 0 {
+				// Deleted by retention while exporting
+				return nil
+			}
+			for _, event := range events {
+				if q.Match(event) {
+					if err := fn(event); err != nil {
+						return err
+					}
+				}
+			}
+			from = start + int64(len(events))
+		}
+		return nil
+	}
+
+	positions = clipPositions(positions, first, end)
+	for len(positions) > 0 {
+		chunk := positions[:min(len(positions), maxHistoryLimit)]
+		positions = positions[len(chunk):]
+
+		events, err := s.readPositions(chunk)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if exact || q.Match(event) {
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// exportWriter writes events in an export format
+type exportWriter struct {
+	format string
+	w      *bufio.Writer
+	csv    *csv.Writer
+}
+
+func newExportWriter(w io.Writer, format string) *exportWriter {
+	ew := &exportWriter{format: format, w: bufio.NewWriter(w)}
+	if format == exportCSV {
+		ew.csv = csv.NewWriter(ew.w)
+		ew.csv.Write(csvHeader)
+	}
+	return ew
+}
+
+func (ew *exportWriter) write(event models.LogEvent) error {
+	event.Level = query.Level(event.Level, event.Message)
+
+	switch ew.format {
+	case exportCSV:
+		var fields string
+		if len(event.Fields) > 0 {
+			data, err := json.Marshal(event.Fields)
+			if err != nil {
+				return err
+			}
+			fields = string(data)
+		}
+		return ew.csv.Write([]string{
+			strconv.FormatInt(event.Seq, 10),
+			event.Timestamp.Format(time.RFC3339Nano),
+			event.Level,
+			event.Source,
+			event.Stream,
+			event.Message,
+			strings.Join(event.Details, "\n"),
+			fields,
+		})
+
+	case exportText:
+		// The lines as logged, with any continuation lines; write errors
+		// stick, so the last write reports them
+		ew.w.WriteString(event.Message)
+		for _, detail := range event.Details {
+			ew.w.WriteByte('\n')
+			ew.w.WriteString(detail)
+		}
+		return ew.w.WriteByte('\n')
+
+	default:
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		ew.w.Write(data)
+		return ew.w.WriteByte('\n')
+	}
+}
+
+func (ew *exportWriter) flush() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		if err := ew.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return ew.w.Flush()
+}
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+const (
+	defaultHistoryLimit = 200
+	maxHistoryLimit     = 1000
+
+	// maxHistoryScan bounds how many events one filtered history request
+	// reads, so a rare match can't make it scan the whole store
+	maxHistoryScan = 50000
+)
+
+// historyResponse is a page of older events, oldest first
+type historyResponse struct {
+	Events []models.LogEvent `json:"events"`
+
+	// NextBefore is the cursor of the next, older page; absent when there
+	// is nothing older
+	NextBefore *int64 `json:"nextBefore,omitempty"`
+
+	// NextAfter is the cursor of the next, newer page when paging forward
+	// with after; absent when the page reaches the newest event
+	NextAfter *int64 `json:"nextAfter,omitempty"`
+}
+
+// indexHistory adds the stored events to the search index
+func (s *Server) indexHistory() error {
+	first, next := s.history.First(), s.history.Next()
+	for from := first; from < next; from += maxHistoryLimit {
+		events, start, err := s.history.Read(from, min(from+maxHistoryLimit, next))
+		if err != nil {
+			return err
+		}
+		for i, event := range events {
+			s.index.Add(start+int64(i), event)
+		}
+	}
+	return nil
+}
+
+// restoreHistory loads the newest stored events into the snapshot and
+// returns the part of the collector's snapshot that comes after them. A
+// restarted collector re-reads recent lines that were already stored, so
+// the snapshot is resumed after the last stored event, if it's found.
+func (s *Server) restoreHistory(snapshot []models.LogEvent) ([]models.LogEvent, error) {
+	next := s.history.Next()
+	tail, first, err := s.history.Read(next-maxSnapshotSize, next)
+	if err != nil {
+		return nil, err
+	}
+
+	s.snapshotMu.Lock()
+	s.snapshot = tail
+	s.snapshotBase = first
+	s.snapshotMu.Unlock()
+
+	if len(tail) == 0 {
+		return snapshot, nil
+	}
+
+	last := tail[len(tail)-1]
+	for i := len(snapshot) - 1; i >= 0; i-- {
+		if sameEvent(snapshot[i], last) {
+			return snapshot[i+1:], nil
+		}
+	}
+	return snapshot, nil
+}
+
+// sameEvent reports whether two events are the same log entry
+func sameEvent(a, b models.LogEvent) bool {
+	return a.Message == b.Message && a.Source == b.Source && a.Stream == b.Stream
+}
+
+// handleHistory returns events older than the before cursor, from the store
+// when one is configured and from memory otherwise.
+// Parameters: before (cursor), at (a time to start from instead of before),
+// limit (page size) and q (an optional search query).
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	filter, err := parseFilter(params.Get("q"))
+	if err != nil {
+		http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if v := params.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxHistoryLimit)
+	}
+
+	first, end := s.historyRange()
+	if v := params.Get("before"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid before cursor", http.StatusBadRequest)
+			return
+		}
+		end = min(end, before)
+	}
+	if v := params.Get("at"); v != "" && s.history != nil {
+		at, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			http.Error(w, "invalid at time (expected RFC 3339)", http.StatusBadRequest)
+			return
+		}
+		// The page starts at the first event at or after at
+		end = min(end, s.history.Seek(at)+int64(limit))
+	}
+
+	var page []models.LogEvent
+	scanned := int64(0)
+	pos := end
+	for pos > first && len(page) < limit && scanned < maxHistoryScan {
+		chunk := int64(limit)
+		if filter != nil {
+			chunk = maxHistoryLimit
+		}
+		from := max(first, pos-chunk)
+
+		events, start, err := s.readHistory(from, pos)
+		if err != nil {
+			http.Error(w, "failed to read history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		scanned += pos - start
+
+		// Walk the chunk newest first, stopping once the page is full
+		for i := len(events) - 1; i >= 0; i-- {
+			if filter == nil || filter.Match(events[i]) {
+				if len(page) == limit {
+					break
+				}
+				page = append(page, events[i])
+			}
+			pos = start + int64(i)
+		}
+		if len(events) == 0 {
+			// Deleted by retention while reading
+			break
+		}
+	}
+
+	resp := historyResponse{Events: make([]models.LogEvent, 0, len(page))}
+	for i := len(page) - 1; i >= 0; i-- {
+		resp.Events = append(resp.Events, page[i])
+	}
+	if pos > first {
+		resp.NextBefore = &pos
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleEvents pages through the retained events by sequence ID: the
+// events before the before cursor, newest page first, or those after the
+// after cursor, for a client catching up on what it missed. Without a
+// cursor it returns the newest page.
+// Parameters: before or after (sequence IDs) and limit (page size).
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	limit := defaultHistoryLimit
+	if v := params.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxHistoryLimit)
+	}
+
+	before, after := params.Get("before"), params.Get("after")
+	if before != "" && after != "" {
+		http.Error(w, "use either before or after, not both", http.StatusBadRequest)
+		return
+	}
+
+	first, end := s.historyRange()
+	from, to := max(first, end-int64(limit)), end
+	if before != "" {
+		seq, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid before cursor", http.StatusBadRequest)
+			return
+		}
+		to = max(first, min(end, seq))
+		from = max(first, to-int64(limit))
+	}
+	if after != "" {
+		seq, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid after cursor", http.StatusBadRequest)
+			return
+		}
+		from = min(end, max(first, seq+1))
+		to = min(end, from+int64(limit))
+	}
+
+	events, start, err := s.readHistory(from, to)
+	if err != nil {
+		http.Error(w, "failed to read events: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := historyResponse{Events: events}
+	if resp.Events == nil {
+		resp.Events = []models.LogEvent{}
+	}
+	if after == "" && start > first {
+		resp.NextBefore = &start
+	}
+	if after != "" && to < end {
+		last := to - 1
+		resp.NextAfter = &last
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// historyRange returns the positions of the oldest event available and the
+// one after the newest
+func (s *Server) historyRange() (first, end int64) {
+	if s.history != nil {
+		return s.history.First(), s.history.Next()
+	}
+
+	s.snapshotMu.RLock()
+	defer s.snapshotMu.RUnlock()
+	return s.snapshotBase, s.snapshotBase + int64(len(s.snapshot))
+}
+
+// readHistory returns the events with positions in [from, to) and the
+// position of the first one
+func (s *Server) readHistory(from, to int64) ([]models.LogEvent, int64, error) {
+	if s.history != nil {
+		return s.history.Read(from, to)
+	}
+
+	s.snapshotMu.RLock()
+	defer s.snapshotMu.RUnlock()
+
+	from = max(from, s.snapshotBase)
+	to = min(to, s.snapshotBase+int64(len(s.snapshot)))
+	if from >= to {
+		return nil, from, nil
+	}
+
+	events := make([]models.LogEvent, to-from)
+	copy(events, s.snapshot[from-s.snapshotBase:to-s.snapshotBase])
+	return events, from, nil
+}
+
+// readPositions returns the events at the given ascending positions. Any
+// that are no longer retained are skipped, so the events returned are
+// those of the last len(events) positions.
+func (s *Server) readPositions(positions []int64) ([]models.LogEvent, error) {
+	if s.history != nil {
+		return s.history.ReadPositions(positions)
+	}
+
+	s.snapshotMu.RLock()
+	defer s.snapshotMu.RUnlock()
+
+	events := make([]models.LogEvent, 0, len(positions))
+	for _, pos := range positions {
+		if pos >= s.snapshotBase && pos < s.snapshotBase+int64(len(s.snapshot)) {
+			events = append(events, s.snapshot[pos-s.snapshotBase])
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// statusList keeps the latest status event of each source, so clients that
+// connect later see whether a source is still waiting or failing
+type statusList struct {
+	mu     sync.Mutex
+	latest map[string]models.StatusEvent // by source
+}
+
+func (l *statusList) add(event models.StatusEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.latest == nil {
+		l.latest = make(map[string]models.StatusEvent)
+	}
+	l.latest[event.Source] = event
+}
+
+// list returns the latest status event of each source, oldest first
+func (l *statusList) list() []models.StatusEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make([]models.StatusEvent, 0, len(l.latest))
+	for _, event := range l.latest {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events
+}
+
+// broadcastStatus sends the collector's status events to every client as
+// "status" messages, apart from log events
+func (s *Server) broadcastStatus() {
+	for event := range s.statusChan {
+		log.Printf("%s: %s", event.Source, event.Message)
+		s.statuses.add(event)
+		s.sendAll(map[string]interface{}{
+			"type":   "status",
+			"status": event,
+		})
+	}
+}
+
+// sendAll queues a message for every client. Streams skip messages without
+// events.
+func (s *Server) sendAll(message map[string]interface{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for c := range s.clients {
+		c.mu.Lock()
+		c.enqueue(outgoing{message: message})
+		c.mu.Unlock()
+	}
+}
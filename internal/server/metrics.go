@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/monstarlab/shepai/internal/metrics"
+)
+
+// handleMetrics writes the ingestion counts, and the connected clients and
+// their queues, in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	s.metrics.Write(w)
+
+	transports := map[string]int64{"websocket": 0, streamSSE: 0, streamNDJSON: 0}
+	var queued int64
+	s.mu.RLock()
+	for c := range s.clients {
+		stats := c.stats()
+		transports[stats.Transport]++
+		queued += int64(stats.Queued)
+	}
+	s.mu.RUnlock()
+
+	clients := make([]metrics.Sample, 0, len(transports))
+	for transport, n := range transports {
+		clients = append(clients, metrics.Sample{
+			Labels: []metrics.Label{{Name: "transport", Value: transport}},
+			Value:  n,
+		})
+	}
+	metrics.Write(w, "shepai_clients", metrics.Gauge, "Connected WebSocket and streaming clients.", clients...)
+	metrics.Write(w, "shepai_queued_events", metrics.Gauge, "Live events waiting to be written to clients.", metrics.Sample{Value: queued})
+	metrics.Write(w, "shepai_dropped_events_total", metrics.Counter, "Live events dropped from full client queues.",
+		metrics.Sample{Value: s.queueMetrics.dropped.Load()})
+	metrics.Write(w, "shepai_disconnected_clients_total", metrics.Counter, "Clients disconnected for a full queue.",
+		metrics.Sample{Value: s.queueMetrics.disconnected.Load()})
+}
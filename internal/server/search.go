@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = maxSnapshotSize
+
+	// maxSearchScan bounds how many events one search checks when the index
+	// can't answer it exactly
+	maxSearchScan = 100000
+)
+
+// searchResponse is a page of search results, oldest first. Pages are
+// walked from the newest event backwards by passing NextBefore as before.
+type searchResponse struct {
+	Query  string            `json:"query"`
+	Events []models.LogEvent `json:"events"`
+
+	// Total is the number of matching events retained
+	Total int `json:"total"`
+
+	// NextBefore is the cursor of the next, older page; absent on the last page
+	NextBefore *int64 `json:"nextBefore,omitempty"`
+
+	// Truncated is set when only the newest maxSearchScan candidate events
+	// were checked, so older matches are missing from Total and the pages
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// handleSearch searches the retained events: those in the store when one is
+// configured, or in memory otherwise.
+// Parameters: q (query), limit (page size) and before (cursor).
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	q, err := query.Parse(params.Get("q"))
+	if err != nil {
+		http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if v := params.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxSearchLimit)
+	}
+
+	first, end := s.historyRange()
+	before := end
+	if v := params.Get("before"); v != "" {
+		before, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid before cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp := searchResponse{Query: q.String(), Events: []models.LogEvent{}}
+	positions, exact, ok := q.Candidates(s.index)
+	if ok {
+		positions = clipPositions(positions, first, end)
+	} else if !q.Empty() {
+		positions = positionRange(max(first, end-maxSearchScan), end)
+		resp.Truncated = end-first > maxSearchScan
+	}
+
+	switch {
+	case q.Empty():
+		err = s.searchAll(&resp, first, end, before, limit)
+	case exact:
+		err = s.searchExact(&resp, positions, before, limit)
+	default:
+		if len(positions) > maxSearchScan {
+			positions = positions[len(positions)-maxSearchScan:]
+			resp.Truncated = true
+		}
+		err = s.searchCandidates(&resp, q, positions, before, limit)
+	}
+	if err != nil {
+		http.Error(w, "search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// searchAll fills resp for a query matching every event in [first, end)
+func (s *Server) searchAll(resp *searchResponse, first, end, before int64, limit int) error {
+	resp.Total = int(end - first)
+
+	to := min(before, end)
+	from := max(first, to-int64(limit))
+	if from > first {
+		resp.NextBefore = &from
+	}
+
+	events, _, err := s.readHistory(from, to)
+	if err != nil {
+		return err
+	}
+	resp.Events = append(resp.Events, events...)
+	return nil
+}
+
+// searchExact fills resp from positions that all match, reading only the
+// events on the page
+func (s *Server) searchExact(resp *searchResponse, positions []int64, before int64, limit int) error {
+	resp.Total = len(positions)
+
+	older := positions[:sort.Search(len(positions), func(i int) bool { return positions[i] >= before })]
+	page := older[max(0, len(older)-limit):]
+	if len(page) < len(older) {
+		resp.NextBefore = &page[0]
+	}
+
+	events, err := s.readPositions(page)
+	if err != nil {
+		return err
+	}
+	resp.Events = append(resp.Events, events...)
+	return nil
+}
+
+// searchCandidates fills resp by checking every candidate, newest first
+func (s *Server) searchCandidates(resp *searchResponse, q *query.Query, positions []int64, before int64, limit int) error {
+	var page []models.LogEvent
+	oldest := int64(-1)
+	for hi := len(positions); hi > 0; {
+		lo := max(0, hi-maxSearchLimit)
+		chunk := positions[lo:hi]
+		hi = lo
+
+		events, err := s.readPositions(chunk)
+		if err != nil {
+			return err
+		}
+		// Events no longer retained are missing from the front
+		chunk = chunk[len(chunk)-len(events):]
+
+		for i := len(events) - 1; i >= 0; i-- {
+			pos := chunk[i]
+			if !q.Match(events[i]) {
+				continue
+			}
+
+			resp.Total++
+			if pos < before && len(page) < limit {
+				page = append(page, events[i])
+				oldest = pos
+			} else if pos < before && resp.NextBefore == nil {
+				// There is at least one more match before this page
+				resp.NextBefore = &oldest
+			}
+		}
+	}
+
+	// Matches were collected newest first
+	for i := len(page) - 1; i >= 0; i-- {
+		resp.Events = append(resp.Events, page[i])
+	}
+	return nil
+}
+
+// positionRange returns the positions in [from, to)
+func positionRange(from, to int64) []int64 {
+	positions := make([]int64, 0, max(0, to-from))
+	for pos := from; pos < to; pos++ {
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+// clipPositions returns the ascending positions within [from, to)
+func clipPositions(positions []int64, from, to int64) []int64 {
+	lo := sort.Search(len(positions), func(i int) bool { return positions[i] >= from })
+	hi := sort.Search(len(positions), func(i int) bool { return positions[i] >= to })
+	return positions[lo:hi]
+}
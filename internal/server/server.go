@@ -2,83 +2,153 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/fs"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/monstarlab/shepai/internal/index"
+	"github.com/monstarlab/shepai/internal/metrics"
 	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+	"github.com/monstarlab/shepai/internal/store"
 )
 
 //go:embed static/*
 var staticFiles embed.FS
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// Only allow localhost connections for security
-		origin := r.Header.Get("Origin")
-		host := r.Host
-
-		// Extract hostname from origin if present
-		if origin != "" {
-			// Parse origin URL
-			if len(origin) >= 7 && (origin[:7] == "http://" || origin[:8] == "https://") {
-				var originHost string
-				if origin[:7] == "http://" {
-					originHost = origin[7:]
-				} else {
-					originHost = origin[8:]
-				}
-				// Remove port if present
-				if idx := len(originHost); idx > 0 {
-					for i, c := range originHost {
-						if c == ':' || c == '/' {
-							originHost = originHost[:i]
-							break
-						}
-					}
-				}
-				// Check if origin host is localhost
-				if originHost == "127.0.0.1" || originHost == "localhost" || originHost == "" {
-					return true
-				}
-			}
-		}
+	// Negotiate permessage-deflate; log lines compress well
+	EnableCompression: true,
+	// Only allow localhost connections for security
+	CheckOrigin: localOrigin,
+}
 
-		// Fallback: check Host header
-		hostname := host
-		if idx := len(host); idx > 0 {
-			for i, c := range host {
-				if c == ':' {
-					hostname = host[:i]
-					break
-				}
-			}
-		}
-		return hostname == "127.0.0.1" || hostname == "localhost"
-	},
+// localOrigin reports whether a request comes from a page on this machine:
+// its Origin, or without one (e.g. curl) its Host, must be localhost. It
+// keeps other sites open in the browser from using the WebSocket or
+// changing state.
+func localOrigin(r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && isLocalhost(u.Hostname())
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return isLocalhost(host)
+}
+
+func isLocalhost(host string) bool {
+	return host == "127.0.0.1" || host == "localhost"
 }
 
 // Server manages the HTTP and WebSocket server
 type Server struct {
 	port       int
 	collector  models.LogCollector
-	clients    map[*websocket.Conn]bool
+	clients    map[*client]bool
 	mu         sync.RWMutex
 	eventChan  chan models.LogEvent
 	snapshot   []models.LogEvent
 	snapshotMu sync.RWMutex
+
+	// snapshotBase is the position of snapshot[0] among all events stored
+	snapshotBase int64
+
+	// history persists every event when a store is configured; nil otherwise
+	history *store.Store
+
+	// storeMu orders appends to history and the snapshot, and storeErr
+	// receives the error that stopped ingest when the store failed
+	storeMu  sync.Mutex
+	storeErr chan error
+
+	// stopBroadcast asks the broadcaster to store what it holds and return;
+	// broadcastDone is closed once it has, so the store can be closed
+	stopBroadcast chan struct{}
+	broadcastDone chan struct{}
+
+	// index covers every retained event: those in history, or in the
+	// snapshot without a store
+	index *index.Index
+
+	// instance identifies the sequence of event IDs, so a client resuming
+	// after a restart without a store isn't replayed unrelated events
+	instance string
+
+	// bookmarks mark events of this instance, per session
+	bookmarks *bookmarkList
+
+	// Status events from the collector, and the latest of each source
+	statusChan chan models.StatusEvent
+	statuses   statusList
+
+	// Live events are sent in batches of up to batchSize, collected for at
+	// most batchWindow
+	batchSize   int
+	batchWindow time.Duration
+
+	// Client queue settings and overflow counts
+	queueSize    int
+	overflow     string
+	queueMetrics queueMetrics
+
+	// metrics counts the events ingested, for /metrics
+	metrics *metrics.Metrics
+
+	// For /api/status
+	version string
+	started time.Time
+	rate    ingestRate
 }
 
+// Options configures optional server features
+type Options struct {
+	// Store keeps events on disk, beyond the in-memory snapshot and across
+	// restarts
+	Store *store.Store
+
+	// QueueSize is how many live events may wait to be written to a client
+	// (default: DefaultQueueSize)
+	QueueSize int
+
+	// Overflow is what happens when a client's queue is full: OverflowDrop
+	// (the default) or OverflowDisconnect
+	Overflow string
+
+	// Metrics are shared with the collector, which counts its parse
+	// failures and reconnects; the server counts events. Created when nil.
+	Metrics *metrics.Metrics
+
+	// MetricsOnly serves /metrics without the dashboard and its APIs, to
+	// run shepai as a log-derived metrics exporter
+	MetricsOnly bool
+
+	// Version is reported by /api/status
+	Version string
+}
+
+// maxSnapshotSize is how many recent events are kept in memory
+const maxSnapshotSize = 1000
+
 // isPortAvailable checks if a port is available for binding
 // It tries to connect to the port first to see if something is already listening,
 // then tries to bind to ensure we can use it
@@ -123,26 +193,78 @@ func findAvailablePort(preferredPort int) int {
 }
 
 // NewServer creates a new server instance
-func NewServer(port int, collector models.LogCollector) *Server {
+func NewServer(port int, collector models.LogCollector, opts Options) *Server {
+	instance := newInstanceID()
+	if opts.Store != nil {
+		instance = opts.Store.ID()
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.Overflow == "" {
+		opts.Overflow = OverflowDrop
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = metrics.New()
+	}
+
 	return &Server{
 		port:      port,
 		collector: collector,
-		clients:   make(map[*websocket.Conn]bool),
+		clients:   make(map[*client]bool),
 		eventChan: make(chan models.LogEvent, 100),
+
+		statusChan: make(chan models.StatusEvent, 16),
+		storeErr:   make(chan error, 1),
+
+		stopBroadcast: make(chan struct{}),
+		broadcastDone: make(chan struct{}),
+
+		history:     opts.Store,
+		index:       index.New(indexChunkSize(opts.Store)),
+		instance:    instance,
+		bookmarks:   &bookmarkList{},
+		batchSize:   maxBatchSize,
+		batchWindow: batchWindow,
+		queueSize:   opts.QueueSize,
+		overflow:    opts.Overflow,
+		metrics:     opts.Metrics,
+		version:     opts.Version,
+		started:     time.Now(),
+	}
+}
+
+// indexChunkSize is the size of the index's chunks: small without a store,
+// so the index doesn't keep much more than the snapshot
+func indexChunkSize(history *store.Store) int64 {
+	if history == nil {
+		return maxSnapshotSize / 4
 	}
+	return index.DefaultChunkSize
+}
+
+// newInstanceID returns a random ID for a server without a store
+func newInstanceID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
 }
 
 // Start starts the server on the preferred port, or finds the next available port if occupied
-func Start(preferredPort int, collector models.LogCollector) error {
+func Start(preferredPort int, collector models.LogCollector, opts Options) error {
 	actualPort := findAvailablePort(preferredPort)
 
 	if actualPort != preferredPort {
 		fmt.Printf("Port %d is in use, using port %d instead\n", preferredPort, actualPort)
 	}
 
-	fmt.Printf("Starting shepai on http://127.0.0.1:%d\n", actualPort)
+	if opts.MetricsOnly {
+		fmt.Printf("Serving metrics on http://127.0.0.1:%d/metrics\n", actualPort)
+	} else {
+		fmt.Printf("Starting shepai on http://127.0.0.1:%d\n", actualPort)
+	}
 
-	s := NewServer(actualPort, collector)
+	s := NewServer(actualPort, collector, opts)
 
 	// Get initial snapshot
 	snapshot, err := collector.GetSnapshot()
@@ -150,50 +272,46 @@ func Start(preferredPort int, collector models.LogCollector) error {
 		return fmt.Errorf("failed to get snapshot: %w", err)
 	}
 
-	// Start collector
+	// Continue from the stored history, skipping the part of the snapshot
+	// that was already stored before a restart
+	if s.history != nil {
+		if err := s.indexHistory(); err != nil {
+			return fmt.Errorf("failed to index history: %w", err)
+		}
+		snapshot, err = s.restoreHistory(snapshot)
+		if err != nil {
+			return fmt.Errorf("failed to load history: %w", err)
+		}
+		if s.bookmarks, err = loadBookmarks(s.bookmarksPath()); err != nil {
+			return err
+		}
+	}
+
+	// Store snapshot for new connections, before live events can arrive
+	if _, err := s.append(snapshot...); err != nil {
+		return fmt.Errorf("failed to store events: %w", err)
+	}
+	s.pruneBookmarks()
+
+	// Start collector, sending its status events apart from log events
+	if n, ok := collector.(models.StatusNotifier); ok {
+		n.NotifyStatus(s.statusChan)
+	}
 	if err := collector.Start(s.eventChan); err != nil {
 		return fmt.Errorf("failed to start collector: %w", err)
 	}
 
-	// Start broadcaster
+	// Start broadcasters
 	go s.broadcast()
-
-	// Store snapshot for new connections
-	s.snapshotMu.Lock()
-	s.snapshot = snapshot
-	s.snapshotMu.Unlock()
+	go s.broadcastStatus()
 
 	// Setup routes
 	mux := http.NewServeMux()
-	mux.HandleFunc("/ws", s.handleWebSocket)
-	mux.HandleFunc("/api/snapshot", s.handleSnapshot)
-
-	// Serve static files
-	staticFS, err := fs.Sub(staticFiles, "static")
-	if err != nil {
-		// If static files don't exist, serve a simple message
-		log.Printf("Warning: static files not found, serving fallback")
-		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/" {
-				w.Header().Set("Content-Type", "text/html")
-				w.Write([]byte(`<!DOCTYPE html>
-<html>
-<head>
-	<title>shepai - Log Viewer</title>
-	<meta charset="UTF-8">
-	<meta name="viewport" content="width=device-width, initial-scale=1.0">
-</head>
-<body>
-	<h1>shepai</h1>
-	<p>Frontend not built. Please run 'make frontend' or 'npm run build' in the frontend directory.</p>
-</body>
-</html>`))
-			} else {
-				http.NotFound(w, r)
-			}
-		})
-	} else {
-		mux.Handle("/", http.FileServer(http.FS(staticFS)))
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/api/status", s.handleStatus)
+	if !opts.MetricsOnly {
+		s.routes(mux)
 	}
 
 	// Create HTTP server
@@ -218,40 +336,122 @@ func Start(preferredPort int, collector models.LogCollector) error {
 		}
 	}()
 
-	// Wait for interrupt signal or server error
+	// Wait for interrupt signal, a store failure or server error
+	var storeErr error
 	select {
 	case <-sigChan:
 		log.Println("Shutting down server...")
+	case storeErr = <-s.storeErr:
+		log.Printf("Error storing events, shutting down: %v", storeErr)
+	case err := <-serverErr:
+		return fmt.Errorf("server error: %w", err)
+	}
 
-		// Stop collector
-		if err := collector.Stop(); err != nil {
-			log.Printf("Error stopping collector: %v", err)
-		}
+	// Stop collector, then wait for the broadcaster to store the events it
+	// already has before closing the store under it
+	if err := collector.Stop(); err != nil {
+		log.Printf("Error stopping collector: %v", err)
+	}
+	close(s.stopBroadcast)
+	<-s.broadcastDone
 
-		// Close all WebSocket connections
-		s.mu.Lock()
-		for conn := range s.clients {
-			conn.Close()
+	if s.history != nil {
+		if err := s.history.Close(); err != nil {
+			log.Printf("Error closing store: %v", err)
 		}
-		s.mu.Unlock()
+	}
 
-		// Shutdown HTTP server
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+	// Close all WebSocket connections
+	s.mu.Lock()
+	for c := range s.clients {
+		c.close()
+	}
+	s.mu.Unlock()
 
-		if err := srv.Shutdown(ctx); err != nil {
-			return fmt.Errorf("shepai shutdown error: %w", err)
-		}
+	// Shutdown HTTP server
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-		log.Println("shepai stopped")
-		return nil
-	case err := <-serverErr:
-		return fmt.Errorf("server error: %w", err)
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shepai shutdown error: %w", err)
+	}
+	if storeErr != nil {
+		return fmt.Errorf("failed to store events: %w", storeErr)
+	}
+
+	log.Println("shepai stopped")
+	return nil
+}
+
+// routes registers the dashboard, its WebSocket and the HTTP APIs
+func (s *Server) routes(mux *http.ServeMux) {
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/api/snapshot", s.handleSnapshot)
+	mux.HandleFunc("/api/correlate", s.handleCorrelate)
+	mux.HandleFunc("/api/search", s.handleSearch)
+	mux.HandleFunc("/api/history", s.handleHistory)
+	mux.HandleFunc("/api/events", s.handleEvents)
+	mux.HandleFunc("/api/stream", s.handleStream)
+	mux.HandleFunc("/api/export", s.handleExport)
+	mux.HandleFunc("/api/clients", s.handleClients)
+	mux.HandleFunc("/api/bookmarks", s.handleBookmarks)
+
+	// Serve static files
+	staticFS, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		// If static files don't exist, serve a simple message
+		log.Printf("Warning: static files not found, serving fallback")
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte(`<!DOCTYPE html>
+<html>
+<head>
+	<title>shepai - Log Viewer</title>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+</head>
+<body>
+	<h1>shepai</h1>
+	<p>Frontend not built. Please run 'make frontend' or 'npm run build' in the frontend directory.</p>
+</body>
+</html>`))
+			} else {
+				http.NotFound(w, r)
+			}
+		})
+	} else {
+		mux.Handle("/", http.FileServer(http.FS(staticFS)))
 	}
 }
 
-// handleWebSocket handles WebSocket connections
+// handleWebSocket handles WebSocket connections. A client may pass a
+// filter query in the URL (/ws?filter=level:error) or send
+// {"type":"subscribe","filter":"..."} at any time to receive only matching
+// events; each subscribe is answered with a matching snapshot. Sending
+// {"type":"pause"} holds live events on the server until
+// {"type":"unpause"}, which is answered with a summary of them before
+// they're sent. The session parameter picks whose bookmarks the client gets
+// (see handleBookmarks).
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	filter, err := parseFilter(params.Get("filter"))
+	if err != nil {
+		http.Error(w, "invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resumeAfter, err := parseResume(params.Get("resume"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	session, ok := parseSession(w, params.Get("session"))
+	if !ok {
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
@@ -267,42 +467,19 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// Add client
-	s.mu.Lock()
-	s.clients[conn] = true
-	s.mu.Unlock()
+	c := s.newClient(wsConn{conn}, "websocket", conn.RemoteAddr().String(), session)
+	go c.writeLoop()
+	s.addClient(c)
 
-	// Send snapshot immediately
-	s.snapshotMu.RLock()
-	snapshotCopy := make([]models.LogEvent, len(s.snapshot))
-	copy(snapshotCopy, s.snapshot)
-	s.snapshotMu.RUnlock()
-
-	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-	if err := conn.WriteJSON(map[string]interface{}{
-		"type":       "snapshot",
-		"events":     snapshotCopy,
-		"sourceName": s.collector.GetSourceName(),
-	}); err != nil {
-		log.Printf("Error sending snapshot: %v", err)
-		s.removeClient(conn)
-		return
+	// Send snapshot immediately, or what the client missed when resuming
+	if resumeAfter < 0 {
+		s.subscribe(c, filter)
+	} else {
+		s.resume(c, filter, resumeAfter, params.Get("instance"))
 	}
 
-	// Keep connection alive and handle pings
-	ticker := time.NewTicker(54 * time.Second)
-	defer ticker.Stop()
-
-	go func() {
-		for range ticker.C {
-			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
-			}
-		}
-	}()
-
 	for {
-		_, _, err := conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
@@ -310,9 +487,78 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+
+		if err := s.handleClientMessage(c, data); err != nil {
+			log.Printf("Error handling client message: %v", err)
+			break
+		}
+	}
+
+	s.removeClient(c)
+}
+
+// handleClientMessage handles a message from a client. Malformed messages
+// and invalid filters are reported back to the client; errors reading the
+// events to replay are returned.
+func (s *Server) handleClientMessage(c *client, data []byte) error {
+	var msg clientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.sendError("invalid message: " + err.Error())
+		return nil
+	}
+
+	switch msg.Type {
+	case messageSubscribe:
+		filter, err := parseFilter(msg.Filter)
+		if err != nil {
+			c.sendError("invalid filter: " + err.Error())
+			return nil
+		}
+		s.subscribe(c, filter)
+		return nil
+	case messageResume:
+		filter, err := parseFilter(msg.Filter)
+		if err != nil {
+			c.sendError("invalid filter: " + err.Error())
+			return nil
+		}
+		if msg.Seq == nil || *msg.Seq < 0 {
+			c.sendError("resume needs the last sequence ID seen as seq")
+			return nil
+		}
+		s.resume(c, filter, *msg.Seq, msg.Instance)
+		return nil
+	case messagePause:
+		c.pause()
+		return nil
+	case messageUnpause:
+		c.unpause()
+		return nil
+	default:
+		c.sendError(fmt.Sprintf("unknown message type %q", msg.Type))
+		return nil
 	}
+}
 
-	s.removeClient(conn)
+// parseFilter parses a subscription filter; empty means no filter
+func parseFilter(filter string) (*query.Query, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+	return query.Parse(filter)
+}
+
+// parseResume parses the last sequence ID a reconnecting client saw; -1
+// when it isn't resuming
+func parseResume(resume string) (int64, error) {
+	if resume == "" {
+		return -1, nil
+	}
+	seq, err := strconv.ParseInt(resume, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, fmt.Errorf("invalid resume sequence ID")
+	}
+	return seq, nil
 }
 
 // handleSnapshot returns the current snapshot
@@ -327,48 +573,88 @@ func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// broadcast sends events to all connected clients
-func (s *Server) broadcast() {
-	const maxSnapshotSize = 1000 // Keep last 1000 events in memory for performance
+// append assigns events their sequence IDs, adds them to the store and the
+// snapshot, and indexes and counts them, trimming the snapshot to
+// maxSnapshotSize to prevent unbounded memory growth. A sequence ID is the
+// event's position among all events stored. If the store fails, only the
+// events stored before the error are added and returned, so sequence IDs
+// never run ahead of the store.
+func (s *Server) append(events ...models.LogEvent) ([]models.LogEvent, error) {
+	// The store is written outside snapshotMu, so readers don't wait for it
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	var err error
+	if s.history != nil && len(events) > 0 {
+		var n int
+		n, err = s.history.Append(events...)
+		events = events[:n]
+	}
 
-	for event := range s.eventChan {
-		message := map[string]interface{}{
-			"type":  "event",
-			"event": event,
-		}
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
 
-		// Update snapshot with new event
-		s.snapshotMu.Lock()
-		s.snapshot = append(s.snapshot, event)
-		// Trim snapshot to maxSnapshotSize to prevent unbounded memory growth
-		if len(s.snapshot) > maxSnapshotSize {
-			// Keep only the most recent events
-			s.snapshot = s.snapshot[len(s.snapshot)-maxSnapshotSize:]
+	source := models.StatusOf(s.collector).Name
+	for i, event := range events {
+		if s.history == nil {
+			event.Seq = s.snapshotBase + int64(len(s.snapshot))
+			events[i] = event
 		}
-		s.snapshotMu.Unlock()
+		s.metrics.Observe(source, event)
+		s.index.Add(event.Seq, event)
+		s.snapshot = append(s.snapshot, event)
+	}
 
-		s.mu.RLock()
-		clients := make([]*websocket.Conn, 0, len(s.clients))
-		for conn := range s.clients {
-			clients = append(clients, conn)
-		}
-		s.mu.RUnlock()
-
-		// Send to all clients
-		for _, conn := range clients {
-			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := conn.WriteJSON(message); err != nil {
-				log.Printf("Error sending to client: %v", err)
-				s.removeClient(conn)
-			}
-		}
+	// Keep only the most recent events
+	if excess := len(s.snapshot) - maxSnapshotSize; excess > 0 {
+		s.snapshot = s.snapshot[excess:]
+		s.snapshotBase += int64(excess)
+	}
+
+	// Drop events from the index as they leave the snapshot, or the store
+	if s.history != nil {
+		s.index.Expire(s.history.First())
+	} else {
+		s.index.Expire(s.snapshotBase)
 	}
+
+	return events, err
+}
+
+// handleClients returns the connected clients and their send queues
+func (s *Server) handleClients(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	clients := make([]clientStats, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c.stats())
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ConnectedAt.Before(clients[j].ConnectedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"clients":      clients,
+		"queueSize":    s.queueSize,
+		"overflow":     s.overflow,
+		"dropped":      s.queueMetrics.dropped.Load(),
+		"disconnected": s.queueMetrics.disconnected.Load(),
+	})
+}
+
+// addClient adds a client to the broadcast list
+func (s *Server) addClient(c *client) {
+	s.mu.Lock()
+	s.clients[c] = true
+	s.mu.Unlock()
 }
 
 // removeClient removes a client from the broadcast list
-func (s *Server) removeClient(conn *websocket.Conn) {
+func (s *Server) removeClient(c *client) {
 	s.mu.Lock()
-	delete(s.clients, conn)
+	delete(s.clients, c)
 	s.mu.Unlock()
-	conn.Close()
+	c.close()
 }
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/store"
+)
+
+func TestAppendStoreFailure(t *testing.T) {
+	history, err := store.Open(store.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(0, benchCollector{}, Options{Store: history})
+
+	stored, err := s.append(models.LogEvent{Message: "a"}, models.LogEvent{Message: "b"})
+	if err != nil || len(stored) != 2 || stored[1].Seq != 1 {
+		t.Fatalf("append = %v, %v", stored, err)
+	}
+
+	// Writes fail once the store is closed; nothing gets a sequence ID the
+	// store can't serve
+	history.Close()
+	stored, err = s.append(models.LogEvent{Message: "c"})
+	if err == nil || len(stored) != 0 {
+		t.Fatalf("append to a closed store = %v, %v", stored, err)
+	}
+	if first, end := s.historyRange(); first != 0 || end != 2 || len(s.snapshot) != 2 {
+		t.Errorf("range [%d, %d) with %d in memory, want [0, 2) with 2", first, end, len(s.snapshot))
+	}
+
+	// The broadcaster stops ingesting and reports the error
+	go s.broadcast()
+	s.eventChan <- models.LogEvent{Message: "d"}
+	select {
+	case err := <-s.storeErr:
+		if err == nil {
+			t.Error("got a nil store error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("store error wasn't reported")
+	}
+}
+
+func TestBroadcastStopStoresPending(t *testing.T) {
+	history, err := store.Open(store.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(0, benchCollector{}, Options{Store: history})
+	s.batchWindow = time.Hour
+
+	// Held in a batch or still in the channel when the server stops
+	go s.broadcast()
+	for _, message := range []string{"a", "b", "c"} {
+		s.eventChan <- models.LogEvent{Message: message}
+	}
+	close(s.stopBroadcast)
+	select {
+	case <-s.broadcastDone:
+	case <-time.After(time.Second):
+		t.Fatal("the broadcaster didn't stop")
+	}
+
+	if err := history.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if first, end := s.historyRange(); first != 0 || end != 3 {
+		t.Errorf("stored [%d, %d), want [0, 3)", first, end)
+	}
+}
+
+func TestLocalOrigin(t *testing.T) {
+	tests := []struct {
+		host   string
+		origin string
+		want   bool
+	}{
+		{"127.0.0.1:4040", "http://127.0.0.1:4040", true},
+		{"localhost:4040", "http://localhost:4040", true},
+		{"127.0.0.1:4040", "https://localhost", true},
+		{"127.0.0.1:4040", "", true},
+		{"localhost", "", true},
+		{"example.com", "", false},
+		{"127.0.0.1:4040", "https://example.com", false},
+		{"127.0.0.1:4040", "http://localhost.example.com", false},
+		{"127.0.0.1:4040", "null", false},
+		{"127.0.0.1:4040", "file://localhost", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.Host = tt.host
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := localOrigin(r); got != tt.want {
+			t.Errorf("localOrigin(Host %q, Origin %q) = %v, want %v", tt.host, tt.origin, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// rateWindow is the period the ingest rate is averaged over
+const rateWindow = time.Minute
+
+// ingestRate counts live events per second over the last rateWindow
+type ingestRate struct {
+	mu      sync.Mutex
+	total   int64
+	last    time.Time // when the last event arrived
+	buckets [int(rateWindow / time.Second)]struct {
+		second int64
+		count  int64
+	}
+}
+
+func (r *ingestRate) add(n int, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sec := now.Unix()
+	b := &r.buckets[sec%int64(len(r.buckets))]
+	if b.second != sec {
+		b.second, b.count = sec, 0
+	}
+	b.count += int64(n)
+	r.total += int64(n)
+	r.last = now
+}
+
+// perSecond returns the average events per second over the last
+// rateWindow, excluding the current, incomplete second
+func (r *ingestRate) perSecond(now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sec := now.Unix()
+	var n int64
+	for _, b := range r.buckets {
+		if b.second < sec && b.second >= sec-int64(len(r.buckets)) {
+			n += b.count
+		}
+	}
+	return float64(n) / float64(len(r.buckets))
+}
+
+// handleStatus describes the server and its sources: the version, uptime,
+// the state of each source, the ingest rate, how full the in-memory buffer
+// and client queues are, and the connected clients
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	s.snapshotMu.RLock()
+	buffer := map[string]interface{}{
+		"events":   len(s.snapshot),
+		"capacity": maxSnapshotSize,
+		"first":    s.snapshotBase,
+		"next":     s.snapshotBase + int64(len(s.snapshot)),
+	}
+	s.snapshotMu.RUnlock()
+
+	transports := map[string]int{"websocket": 0, streamSSE: 0, streamNDJSON: 0}
+	total, queued, paused := 0, 0, 0
+	s.mu.RLock()
+	for c := range s.clients {
+		stats := c.stats()
+		transports[stats.Transport]++
+		queued += stats.Queued
+		if stats.Paused {
+			paused++
+		}
+		total++
+	}
+	s.mu.RUnlock()
+	clients := map[string]interface{}{
+		"total":       total,
+		"byTransport": transports,
+		"paused":      paused,
+		"queued":      queued,
+		"queueSize":   s.queueSize,
+	}
+
+	s.rate.mu.Lock()
+	ingest := map[string]interface{}{"events": s.rate.total}
+	if !s.rate.last.IsZero() {
+		ingest["lastEventAt"] = s.rate.last
+	}
+	s.rate.mu.Unlock()
+	ingest["perSecond"] = s.rate.perSecond(now)
+
+	status := map[string]interface{}{
+		"version":       s.version,
+		"instance":      s.instance,
+		"startedAt":     s.started,
+		"uptimeSeconds": int64(now.Sub(s.started).Seconds()),
+		"sources":       []models.SourceStatus{models.StatusOf(s.collector)},
+		"ingest":        ingest,
+		"buffer":        buffer,
+		"clients":       clients,
+	}
+	if s.history != nil {
+		status["store"] = map[string]interface{}{
+			"dir":   s.history.Dir(),
+			"first": s.history.First(),
+			"next":  s.history.Next(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleHealth answers 200 when every source is being followed, and 503
+// with the state of the others when not, e.g. while waiting for a file or
+// a stopped container
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	var problems []string
+	for _, source := range []models.SourceStatus{models.StatusOf(s.collector)} {
+		switch source.State {
+		case models.SourceFollowing, models.SourceUnknown:
+			continue
+		}
+
+		problem := fmt.Sprintf("%s: %s", source.Name, source.State)
+		if source.Detail != "" {
+			problem += " (" + source.Detail + ")"
+		}
+		if source.Attempt > 0 {
+			problem += fmt.Sprintf(", attempt %d", source.Attempt)
+		}
+		problems = append(problems, problem)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+// Formats of /api/stream
+const (
+	streamSSE    = "sse"
+	streamNDJSON = "ndjson"
+)
+
+// streamConn writes a client's events to an HTTP response, as Server-Sent
+// Events or as newline-delimited JSON
+type streamConn struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	format   string
+	instance string
+}
+
+// write writes the events of a message, or a gap. Other messages have no
+// place in a stream and are skipped.
+func (c *streamConn) write(item outgoing) error {
+	var buf bytes.Buffer
+	switch {
+	case item.live != nil:
+		c.encodeEvents(&buf, item.live.events)
+	case item.gap != nil:
+		c.encodeGap(&buf, item.gap)
+	default:
+		c.encodeEvents(&buf, item.events)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return c.send(buf.Bytes())
+}
+
+// encodeEvents writes each event as an SSE message or a line of JSON. SSE
+// IDs carry the instance, so EventSource can resume with Last-Event-ID.
+func (c *streamConn) encodeEvents(buf *bytes.Buffer, events []models.LogEvent) {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Error encoding event: %v", err)
+			continue
+		}
+		if c.format == streamSSE {
+			fmt.Fprintf(buf, "id: %s:%d\ndata: %s\n\n", c.instance, event.Seq, data)
+		} else {
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+	}
+}
+
+// encodeGap writes a gap as a "gap" SSE event, or a line with "type":"gap"
+func (c *streamConn) encodeGap(buf *bytes.Buffer, g *gap) {
+	data, _ := json.Marshal(g.message())
+	if c.format == streamSSE {
+		fmt.Fprintf(buf, "id: %s:%d\nevent: gap\ndata: %s\n\n", c.instance, g.to-1, data)
+	} else {
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+}
+
+// ping sends an SSE comment to keep proxies from timing out the stream;
+// NDJSON has no way to send nothing
+func (c *streamConn) ping() error {
+	if c.format != streamSSE {
+		return nil
+	}
+	return c.send([]byte(": ping\n\n"))
+}
+
+func (c *streamConn) send(data []byte) error {
+	// Streams outlive the server's write timeout
+	c.rc.SetWriteDeadline(time.Now().Add(writeWait))
+	if _, err := c.w.Write(data); err != nil {
+		return err
+	}
+	return c.rc.Flush()
+}
+
+// close does nothing: the response ends when the handler's writer stops
+func (c *streamConn) close() error {
+	return nil
+}
+
+// handleStream streams events over plain HTTP for clients that can't use a
+// WebSocket: as Server-Sent Events (/api/stream?format=sse, the default
+// when the client accepts text/event-stream) or as newline-delimited JSON
+// (format=ndjson). It takes the same filter, resume, instance and session
+// parameters as /ws and sends the same events: the matching snapshot, or
+// the events missed when resuming, then live events. An EventSource
+// reconnecting with Last-Event-ID resumes where it left off.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	format := params.Get("format")
+	if format == "" {
+		format = streamNDJSON
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			format = streamSSE
+		}
+	}
+	if format != streamSSE && format != streamNDJSON {
+		http.Error(w, fmt.Sprintf("invalid format %q: use %q or %q", format, streamSSE, streamNDJSON), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseFilter(params.Get("filter"))
+	if err != nil {
+		http.Error(w, "invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resumeAfter, err := parseResume(params.Get("resume"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	instance := params.Get("instance")
+	session, ok := parseSession(w, params.Get("session"))
+	if !ok {
+		return
+	}
+
+	// Last-Event-ID is "<instance>:<seq>", as sent in SSE IDs
+	if id := r.Header.Get("Last-Event-ID"); id != "" && format == streamSSE {
+		lastInstance, lastSeq, _ := strings.Cut(id, ":")
+		seq, err := strconv.ParseInt(lastSeq, 10, 64)
+		if err != nil || seq < 0 {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		resumeAfter, instance = seq, lastInstance
+	}
+
+	if format == streamSSE {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+
+	conn := &streamConn{
+		w:        w,
+		rc:       http.NewResponseController(w),
+		format:   format,
+		instance: s.instance,
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := conn.send(nil); err != nil {
+		return
+	}
+
+	c := s.newClient(conn, format, r.RemoteAddr, session)
+	s.addClient(c)
+	defer s.removeClient(c)
+
+	if resumeAfter < 0 {
+		s.subscribe(c, filter)
+	} else {
+		s.resume(c, filter, resumeAfter, instance)
+	}
+
+	// The writer runs here, since the response ends when the handler
+	// returns; it stops when the client goes away
+	stop := context.AfterFunc(r.Context(), c.close)
+	defer stop()
+	c.writeLoop()
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	segmentExt = ".seg"
+
+	// indexInterval is how many records apart the sparse index entries are
+	indexInterval = 64
+)
+
+// indexEntry locates a record inside a segment
+type indexEntry struct {
+	pos    int64 // absolute position of the record
+	offset int64 // byte offset of the record in the segment
+	time   time.Time
+}
+
+// segment is one append-only file of newline-delimited JSON events. The
+// file name is the position of its first record.
+type segment struct {
+	path  string
+	start int64 // position of the first record
+	count int64 // number of records
+	size  int64 // bytes
+
+	index    []indexEntry
+	modified time.Time // last write, for age-based retention
+}
+
+func segmentName(start int64) string {
+	return fmt.Sprintf("%020d%s", start, segmentExt)
+}
+
+// parseSegmentName returns the start position encoded in a segment file name
+func parseSegmentName(name string) (int64, bool) {
+	if !strings.HasSuffix(name, segmentExt) {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+	return start, err == nil && start >= 0
+}
+
+// end returns the position after the last record
+func (seg *segment) end() int64 {
+	return seg.start + seg.count
+}
+
+// load scans the segment to count its records and build its index. A torn
+// last record, left by a crash mid-write, is truncated away.
+func (seg *segment) load(dir string) error {
+	seg.path = filepath.Join(dir, segmentName(seg.start))
+
+	f, err := os.OpenFile(seg.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	seg.modified = stat.ModTime()
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				if err := f.Truncate(offset); err != nil {
+					return fmt.Errorf("failed to truncate torn record: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		seg.track(offset, line)
+		offset += int64(len(line))
+	}
+
+	seg.size = offset
+	return nil
+}
+
+// track accounts for a record written or found at offset
+func (seg *segment) track(offset int64, record []byte) {
+	if seg.count%indexInterval == 0 {
+		seg.index = append(seg.index, indexEntry{
+			pos:    seg.end(),
+			offset: offset,
+			time:   recordTime(record),
+		})
+	}
+	seg.count++
+}
+
+// seek returns the byte offset of the index entry at or before pos, and
+// that entry's position
+func (seg *segment) seek(pos int64) (offset, at int64) {
+	i := int((pos - seg.start) / indexInterval)
+	if i >= len(seg.index) {
+		i = len(seg.index) - 1
+	}
+	return seg.index[i].offset, seg.index[i].pos
+}
+
+// timestampPrefix is how every encoded LogEvent starts
+var timestampPrefix = []byte(`{"timestamp":"`)
+
+// recordTime reads the timestamp of an encoded event without decoding it all
+func recordTime(record []byte) time.Time {
+	if !bytes.HasPrefix(record, timestampPrefix) {
+		return time.Time{}
+	}
+	rest := record[len(timestampPrefix):]
+	end := bytes.IndexByte(rest, '"')
+	if end < 0 {
+		return time.Time{}
+	}
+
+	t, _ := time.Parse(time.RFC3339Nano, string(rest[:end]))
+	return t
+}
@@ -0,0 +1,450 @@
+package store
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+const (
+	// DefaultMaxSize is the default cap on the total size of a store
+	DefaultMaxSize = 1 << 30 // 1 GiB
+
+	// DefaultSegmentSize is the size at which a new segment is started
+	DefaultSegmentSize = 16 << 20 // 16 MiB
+
+	// retentionInterval is how often retention is enforced while appending
+	retentionInterval = time.Minute
+)
+
+// Options configures a store
+type Options struct {
+	// Dir is the store's directory, created if missing
+	Dir string
+
+	// MaxSize caps the total size of the segments; the oldest are deleted
+	// first. Defaults to DefaultMaxSize.
+	MaxSize int64
+
+	// MaxAge deletes segments last written longer ago than this. Zero keeps
+	// segments regardless of age.
+	MaxAge time.Duration
+
+	// SegmentSize defaults to DefaultSegmentSize
+	SegmentSize int64
+}
+
+// Store is an append-only on-disk log of events. Every event gets a
+// position, counting up from 0 across restarts; old segments are deleted
+// according to the retention options.
+type Store struct {
+	opts Options
+	id   string
+
+	mu            sync.Mutex
+	segments      []*segment // oldest first; the last one is written to
+	active        *os.File
+	lastRetention time.Time
+}
+
+// Open opens or creates the store in opts.Dir
+func Open(opts Options) (*Store, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store directory: %w", err)
+	}
+
+	id, err := loadID(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{opts: opts, id: id}
+	for _, entry := range entries {
+		start, ok := parseSegmentName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		seg := &segment{start: start}
+		if err := seg.load(opts.Dir); err != nil {
+			return nil, fmt.Errorf("failed to load segment %s: %w", entry.Name(), err)
+		}
+		s.segments = append(s.segments, seg)
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].start < s.segments[j].start
+	})
+
+	if len(s.segments) == 0 {
+		if err := s.roll(0); err != nil {
+			return nil, err
+		}
+	} else if err := s.openActive(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	err = s.enforceRetention(time.Now())
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// idFile holds the store's ID
+const idFile = "id"
+
+// loadID reads the store's ID, creating one for a new store
+func loadID(dir string) (string, error) {
+	path := filepath.Join(dir, idFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read store ID: %w", err)
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate store ID: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+	if err := os.WriteFile(path, []byte(id+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write store ID: %w", err)
+	}
+	return id, nil
+}
+
+// ID identifies the store. Positions are only meaningful together with it:
+// a new store, e.g. after the directory was deleted, starts again from 0.
+func (s *Store) ID() string {
+	return s.id
+}
+
+// Dir is the store's directory, where other state of the same source can
+// be kept alongside the events
+func (s *Store) Dir() string {
+	return s.opts.Dir
+}
+
+// DirFor returns the directory a source's events are stored in, under root
+func DirFor(root, source string) string {
+	return filepath.Join(root, unsafeNameChars.ReplaceAllString(source, "_"))
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Append stores events, setting the Seq of each one to its position, and
+// returns how many were stored: all of them, or those before an error
+func (s *Store) Append(events ...models.LogEvent) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range events {
+		events[i].Seq = s.next()
+		record, err := json.Marshal(events[i])
+		if err != nil {
+			return i, fmt.Errorf("failed to encode event: %w", err)
+		}
+		record = append(record, '\n')
+
+		seg := s.segments[len(s.segments)-1]
+		if seg.size > 0 && seg.size+int64(len(record)) > s.opts.SegmentSize {
+			if err := s.roll(seg.end()); err != nil {
+				return i, err
+			}
+			seg = s.segments[len(s.segments)-1]
+		}
+
+		if _, err := s.active.Write(record); err != nil {
+			return i, fmt.Errorf("failed to write event: %w", err)
+		}
+		seg.track(seg.size, record)
+		seg.size += int64(len(record))
+		seg.modified = time.Now()
+	}
+
+	if now := time.Now(); now.Sub(s.lastRetention) >= retentionInterval {
+		if err := s.enforceRetention(now); err != nil {
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
+
+// First returns the position of the oldest stored event
+func (s *Store) First() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.segments[0].start
+}
+
+// Next returns the position the next appended event will get
+func (s *Store) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.next()
+}
+
+func (s *Store) next() int64 {
+	return s.segments[len(s.segments)-1].end()
+}
+
+// Read returns the stored events with positions in [from, to), oldest
+// first, and the position of the first one returned. Positions that have
+// been deleted by retention are skipped.
+func (s *Store) Read(from, to int64) ([]models.LogEvent, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from = max(from, s.segments[0].start)
+	to = min(to, s.next())
+	if from >= to {
+		return nil, from, nil
+	}
+
+	events := make([]models.LogEvent, 0, to-from)
+	for _, seg := range s.segments {
+		if seg.end() <= from || seg.start >= to || seg.count == 0 {
+			continue
+		}
+
+		read, err := seg.read(max(from, seg.start), min(to, seg.end()))
+		if err != nil {
+			return nil, from, err
+		}
+		events = append(events, read...)
+	}
+
+	return events, from, nil
+}
+
+// read decodes the records with positions in [from, to)
+func (seg *segment) read(from, to int64) ([]models.LogEvent, error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	offset, pos := seg.seek(from)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	events := make([]models.LogEvent, 0, to-from)
+	for ; pos < to; pos++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", seg.path, err)
+		}
+		if pos < from {
+			continue
+		}
+
+		var event models.LogEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, fmt.Errorf("corrupt record %d in %s: %w", pos, seg.path, err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// ReadPositions returns the stored events at the given ascending positions.
+// Positions deleted by retention are skipped; as retention deletes the
+// oldest events first, the events returned are those of the last
+// len(events) positions.
+func (s *Store) ReadPositions(positions []int64) ([]models.LogEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]models.LogEvent, 0, len(positions))
+	for _, seg := range s.segments {
+		from := sort.Search(len(positions), func(i int) bool { return positions[i] >= seg.start })
+		to := sort.Search(len(positions), func(i int) bool { return positions[i] >= seg.end() })
+		if from == to {
+			continue
+		}
+
+		read, err := seg.readPositions(positions[from:to])
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, read...)
+	}
+
+	return events, nil
+}
+
+// readPositions decodes the records at the given ascending positions,
+// seeking past the records in between using the index
+func (seg *segment) readPositions(positions []int64) ([]models.LogEvent, error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	events := make([]models.LogEvent, 0, len(positions))
+	cur := int64(-1) // position of the record the reader is at
+	for _, pos := range positions {
+		if offset, at := seg.seek(pos); cur < 0 || at > cur {
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+			reader.Reset(f)
+			cur = at
+		}
+
+		for ; cur < pos; cur++ {
+			if err := skipLine(reader); err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", seg.path, err)
+			}
+		}
+
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", seg.path, err)
+		}
+		cur++
+
+		var event models.LogEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, fmt.Errorf("corrupt record %d in %s: %w", pos, seg.path, err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// skipLine advances the reader past the next newline without copying
+func skipLine(reader *bufio.Reader) error {
+	for {
+		_, err := reader.ReadSlice('\n')
+		if err != bufio.ErrBufferFull {
+			return err
+		}
+	}
+}
+
+// Seek returns the position of the first event stored at or after t,
+// judged by the time index. Event timestamps are mostly, but not strictly,
+// increasing, so the result is approximate to within one index interval.
+func (s *Store) Seek(t time.Time) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, seg := range s.segments {
+		for i, entry := range seg.index {
+			if !entry.time.Before(t) {
+				if i == 0 {
+					return entry.pos
+				}
+				// The match is somewhere after the previous entry
+				return seg.index[i-1].pos
+			}
+		}
+	}
+	return s.next()
+}
+
+// Close closes the active segment
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.active.Close()
+}
+
+// roll starts a new segment whose first record will be at start
+func (s *Store) roll(start int64) error {
+	if s.active != nil {
+		if err := s.active.Close(); err != nil {
+			return err
+		}
+	}
+
+	seg := &segment{
+		start:    start,
+		path:     filepath.Join(s.opts.Dir, segmentName(start)),
+		modified: time.Now(),
+	}
+	s.segments = append(s.segments, seg)
+	return s.openActive()
+}
+
+// openActive opens the newest segment for appending
+func (s *Store) openActive() error {
+	seg := s.segments[len(s.segments)-1]
+
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	s.active = f
+	return nil
+}
+
+// enforceRetention deletes the oldest segments while the store is over its
+// size limit or they are older than the age limit. The segment being
+// written to is never deleted.
+func (s *Store) enforceRetention(now time.Time) error {
+	s.lastRetention = now
+
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+
+	for len(s.segments) > 1 {
+		oldest := s.segments[0]
+		tooBig := total > s.opts.MaxSize
+		tooOld := s.opts.MaxAge > 0 && now.Sub(oldest.modified) > s.opts.MaxAge
+		if !tooBig && !tooOld {
+			break
+		}
+
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete segment: %w", err)
+		}
+		total -= oldest.size
+		s.segments = s.segments[1:]
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+var testStart = time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+
+// testEvents returns n events a second apart, numbered from first
+func testEvents(first, n int) []models.LogEvent {
+	events := make([]models.LogEvent, n)
+	for i := range events {
+		events[i] = models.LogEvent{
+			Timestamp: testStart.Add(time.Duration(first+i) * time.Second),
+			Source:    "file",
+			Message:   fmt.Sprintf("event %d", first+i),
+		}
+	}
+	return events
+}
+
+func openStore(t *testing.T, opts Options) *Store {
+	t.Helper()
+	s, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// checkRead reads [from, to) and checks the events are numbered want onwards
+func checkRead(t *testing.T, s *Store, from, to, want int64, count int) {
+	t.Helper()
+	events, start, err := s.Read(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != want || len(events) != count {
+		t.Fatalf("Read(%d, %d) = %d events from %d, want %d from %d", from, to, len(events), start, count, want)
+	}
+	for i, event := range events {
+		if event.Seq != want+int64(i) || event.Message != fmt.Sprintf("event %d", want+int64(i)) {
+			t.Fatalf("Read(%d, %d)[%d] = %d %q", from, to, i, event.Seq, event.Message)
+		}
+	}
+}
+
+func TestAppendAssignsPositions(t *testing.T) {
+	s := openStore(t, Options{Dir: t.TempDir()})
+
+	events := testEvents(0, 3)
+	n, err := s.Append(events...)
+	if err != nil || n != 3 {
+		t.Fatalf("Append = %d, %v", n, err)
+	}
+	for i, event := range events {
+		if event.Seq != int64(i) {
+			t.Errorf("event %d got Seq %d", i, event.Seq)
+		}
+	}
+	if _, err := s.Append(testEvents(3, 2)...); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.First() != 0 || s.Next() != 5 {
+		t.Errorf("range [%d, %d), want [0, 5)", s.First(), s.Next())
+	}
+	checkRead(t, s, 0, 5, 0, 5)
+	checkRead(t, s, 2, 4, 2, 2)
+	checkRead(t, s, -10, 100, 0, 5)
+	checkRead(t, s, 5, 10, 5, 0)
+}
+
+func TestSegmentRollover(t *testing.T) {
+	dir := t.TempDir()
+	// Each event is about 90 bytes, so a segment holds a few
+	s := openStore(t, Options{Dir: dir, SegmentSize: 300})
+
+	for i := 0; i < 20; i++ {
+		if _, err := s.Append(testEvents(i, 1)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(s.segments) < 5 {
+		t.Fatalf("got %d segments, want several", len(s.segments))
+	}
+	for i, seg := range s.segments {
+		if seg.size > 300 {
+			t.Errorf("segment %d is %d bytes", i, seg.size)
+		}
+		if i > 0 && seg.start != s.segments[i-1].end() {
+			t.Errorf("segment %d starts at %d, after %d", i, seg.start, s.segments[i-1].end())
+		}
+		if _, err := os.Stat(filepath.Join(dir, segmentName(seg.start))); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Reads across segment boundaries
+	checkRead(t, s, 0, 20, 0, 20)
+	checkRead(t, s, 3, 17, 3, 14)
+	events, err := s.ReadPositions([]int64{0, 4, 5, 11, 19})
+	if err != nil || len(events) != 5 || events[3].Message != "event 11" {
+		t.Errorf("ReadPositions = %v, %v", events, err)
+	}
+
+	// A batch larger than a segment rolls over inside it
+	if _, err := s.Append(testEvents(20, 10)...); err != nil {
+		t.Fatal(err)
+	}
+	checkRead(t, s, 15, 30, 15, 15)
+
+	// Reopening counts the records of every segment
+	s.Close()
+	reopened := openStore(t, Options{Dir: dir, SegmentSize: 300})
+	if reopened.First() != 0 || reopened.Next() != 30 || reopened.ID() != s.ID() {
+		t.Errorf("reopened: [%d, %d) %s, want [0, 30) %s", reopened.First(), reopened.Next(), reopened.ID(), s.ID())
+	}
+	checkRead(t, reopened, 0, 30, 0, 30)
+}
+
+func TestReopenTruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, Options{Dir: dir})
+	if _, err := s.Append(testEvents(0, 2)...); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	f, err := os.OpenFile(filepath.Join(dir, segmentName(0)), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"timestamp":"2026-10-`)
+	f.Close()
+
+	s = openStore(t, Options{Dir: dir})
+	if s.Next() != 2 {
+		t.Fatalf("Next() = %d, want 2", s.Next())
+	}
+	if _, err := s.Append(testEvents(2, 1)...); err != nil {
+		t.Fatal(err)
+	}
+	checkRead(t, s, 0, 3, 0, 3)
+}
+
+func TestRetention(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts Options
+		now  time.Time
+	}{
+		{"size", Options{MaxSize: 1000, SegmentSize: 300}, time.Now()},
+		{"age", Options{MaxAge: time.Hour, SegmentSize: 300}, time.Now().Add(2 * time.Hour)},
+		{"neither", Options{MaxAge: time.Hour, SegmentSize: 300}, time.Now()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Dir = t.TempDir()
+			s := openStore(t, tc.opts)
+			if _, err := s.Append(testEvents(0, 40)...); err != nil {
+				t.Fatal(err)
+			}
+			before := len(s.segments)
+
+			s.mu.Lock()
+			err := s.enforceRetention(tc.now)
+			s.mu.Unlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var total int64
+			for _, seg := range s.segments {
+				total += seg.size
+			}
+			switch tc.name {
+			case "size":
+				if total > tc.opts.MaxSize || len(s.segments) == before {
+					t.Errorf("%d segments of %d bytes left", len(s.segments), total)
+				}
+			case "age":
+				// Everything is too old, but the segment being written to stays
+				if len(s.segments) != 1 {
+					t.Errorf("%d segments left, want 1", len(s.segments))
+				}
+			case "neither":
+				if len(s.segments) != before {
+					t.Errorf("%d segments left, want %d", len(s.segments), before)
+				}
+			}
+
+			// Deleted segments are gone from disk and from reads
+			first := s.First()
+			if tc.name != "neither" && first == 0 {
+				t.Fatal("nothing was deleted")
+			}
+			for pos := int64(0); pos < first; pos++ {
+				for _, seg := range s.segments {
+					if pos >= seg.start && pos < seg.end() {
+						t.Fatalf("position %d is still in a segment", pos)
+					}
+				}
+			}
+			entries, _ := os.ReadDir(tc.opts.Dir)
+			var segs int
+			for _, entry := range entries {
+				if _, ok := parseSegmentName(entry.Name()); ok {
+					segs++
+				}
+			}
+			if segs != len(s.segments) {
+				t.Errorf("%d segment files for %d segments", segs, len(s.segments))
+			}
+			checkRead(t, s, 0, 40, first, int(40-first))
+			if s.Next() != 40 {
+				t.Errorf("Next() = %d, want 40", s.Next())
+			}
+		})
+	}
+}
+
+func TestSeek(t *testing.T) {
+	s := openStore(t, Options{Dir: t.TempDir(), SegmentSize: 300})
+
+	// An empty store seeks to its end
+	if got := s.Seek(testStart); got != 0 {
+		t.Errorf("empty store: Seek = %d, want 0", got)
+	}
+
+	// Several segments, then an empty one, as after a rollover with
+	// nothing written yet
+	if _, err := s.Append(testEvents(0, 10)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.roll(s.next()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		at   time.Time
+		want int64
+	}{
+		{testStart.Add(-time.Hour), 0},
+		{testStart, 0},
+		{testStart.Add(3 * time.Second), 3},
+		{testStart.Add(9 * time.Second), 9},
+		{testStart.Add(time.Hour), 10}, // past the end, and the empty segment
+	} {
+		got := s.Seek(tc.at)
+		// The index is sparse within a segment, so a seek may land up to one
+		// interval early, but never late
+		if got > tc.want || tc.want-got >= indexInterval {
+			t.Errorf("Seek(%v) = %d, want %d", tc.at, got, tc.want)
+		}
+	}
+
+	// Reads skip the empty segment
+	checkRead(t, s, 0, 100, 0, 10)
+	if positions, err := s.ReadPositions([]int64{9, 10}); err != nil || len(positions) != 1 {
+		t.Errorf("ReadPositions = %v, %v", positions, err)
+	}
+}
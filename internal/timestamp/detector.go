@@ -0,0 +1,111 @@
+package timestamp
+
+import (
+	"sync"
+	"time"
+)
+
+// learnSamples is the number of timestamped lines the detector looks at
+// before settling on the layout a source uses
+const learnSamples = 10
+
+// Detector finds timestamps in log lines. It learns which layout a source
+// uses from the first lines it sees and tries that layout first afterwards,
+// so each source should have its own Detector.
+type Detector struct {
+	loc     *time.Location
+	layouts []Layout
+
+	mu      sync.Mutex
+	counts  map[int]int
+	samples int
+	learned int // index into layouts, -1 while still learning
+}
+
+// NewDetector creates a detector. Timestamps without a zone offset are read
+// in loc (UTC if nil). extraLayouts are Go time layouts from the user config,
+// tried before the built-in ones.
+func NewDetector(loc *time.Location, extraLayouts []string) (*Detector, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	layouts := make([]Layout, 0, len(extraLayouts)+len(builtinLayouts))
+	for _, layout := range extraLayouts {
+		l, err := NewLayout(layout)
+		if err != nil {
+			return nil, err
+		}
+		layouts = append(layouts, l)
+	}
+	layouts = append(layouts, builtinLayouts...)
+
+	return &Detector{
+		loc:     loc,
+		layouts: layouts,
+		counts:  make(map[int]int),
+		learned: -1,
+	}, nil
+}
+
+// Detect returns the timestamp found in line, if any
+func (d *Detector) Detect(line string) (Match, bool) {
+	d.mu.Lock()
+	learned := d.learned
+	d.mu.Unlock()
+
+	if learned >= 0 {
+		if m, ok := d.layouts[learned].find(line, d.loc); ok {
+			return m, true
+		}
+	}
+
+	for i := range d.layouts {
+		if i == learned {
+			continue
+		}
+		if m, ok := d.layouts[i].find(line, d.loc); ok {
+			d.observe(i)
+			return m, true
+		}
+	}
+
+	return Match{}, false
+}
+
+// Layout returns the layout the detector settled on, or "" while still learning
+func (d *Detector) Layout() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.learned < 0 {
+		return ""
+	}
+	return d.layouts[d.learned].Name
+}
+
+// observe records a match while learning and settles on the most common
+// layout once enough samples have been seen
+func (d *Detector) observe(idx int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.learned >= 0 {
+		return
+	}
+
+	d.counts[idx]++
+	d.samples++
+	if d.samples < learnSamples {
+		return
+	}
+
+	best := -1
+	for i, n := range d.counts {
+		if best < 0 || n > d.counts[best] || (n == d.counts[best] && i < best) {
+			best = i
+		}
+	}
+	d.learned = best
+	d.counts = nil
+}
@@ -0,0 +1,261 @@
+package timestamp
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layoutTokens maps Go reference-time tokens to the regex matching them.
+// Longer tokens are listed first so that e.g. "2006" wins over "2".
+var layoutTokens = []struct {
+	token   string
+	pattern string
+}{
+	{"January", `[A-Z][a-z]+`},
+	{"Monday", `[A-Z][a-z]+`},
+	{"-07:00:00", `[+-]\d{2}:\d{2}:\d{2}`},
+	{"Z07:00:00", `(?:Z|[+-]\d{2}:\d{2}:\d{2})`},
+	{"-070000", `[+-]\d{6}`},
+	{"Z070000", `(?:Z|[+-]\d{6})`},
+	{"Z07:00", `(?:Z|[+-]\d{2}:\d{2})`},
+	{"-07:00", `[+-]\d{2}:\d{2}`},
+	{"Z0700", `(?:Z|[+-]\d{4})`},
+	{"-0700", `[+-]\d{4}`},
+	{"2006", `\d{4}`},
+	{"Z07", `(?:Z|[+-]\d{2})`},
+	{"-07", `[+-]\d{2}`},
+	{"Jan", `[A-Z][a-z]{2}`},
+	{"Mon", `[A-Z][a-z]{2}`},
+	{"MST", `[A-Z]{2,5}`},
+	{"002", `\d{3}`},
+	{"_2", `[ \d]\d`},
+	{"01", `\d{2}`},
+	{"02", `\d{2}`},
+	{"03", `\d{2}`},
+	{"04", `\d{2}`},
+	{"05", `\d{2}`},
+	{"06", `\d{2}`},
+	{"15", `\d{2}`},
+	{"PM", `[AP]M`},
+	{"pm", `[ap]m`},
+	{"1", `\d{1,2}`},
+	{"2", `\d{1,2}`},
+	{"3", `\d{1,2}`},
+	{"4", `\d{1,2}`},
+	{"5", `\d{1,2}`},
+}
+
+// fractionRe matches fractional-second tokens such as .000, ,999 or .999999999
+var fractionRe = regexp.MustCompile(`^[.,](0+|9+)`)
+
+// layoutToPattern converts a Go time layout into an equivalent regular expression.
+// time.Parse accepts a fractional second after the seconds field even when the
+// layout doesn't mention one, so the pattern allows it too.
+func layoutToPattern(layout string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(layout); {
+		if m := fractionRe.FindString(layout[i:]); m != "" {
+			if m[1] == '9' {
+				b.WriteString(`(?:[.,]\d+)?`)
+			} else {
+				b.WriteString(`[.,]\d+`)
+			}
+			i += len(m)
+			continue
+		}
+
+		matched := false
+		for _, t := range layoutTokens {
+			if strings.HasPrefix(layout[i:], t.token) {
+				b.WriteString(t.pattern)
+				i += len(t.token)
+				matched = true
+
+				if t.token == "05" && !fractionRe.MatchString(layout[i:]) {
+					b.WriteString(`(?:[.,]\d+)?`)
+				}
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		b.WriteString(regexp.QuoteMeta(layout[i : i+1]))
+		i++
+	}
+
+	return b.String()
+}
+
+// Layout is a timestamp format the detector knows how to find and parse
+type Layout struct {
+	// Name identifies the layout (the Go layout string for layout-based formats)
+	Name string
+
+	re    *regexp.Regexp
+	parse func(value string, loc *time.Location) (time.Time, error)
+}
+
+// Match describes a timestamp found in a line
+type Match struct {
+	Time   time.Time
+	Layout string
+
+	// Start and End are the byte offsets of the timestamp text in the line,
+	// including surrounding brackets
+	Start, End int
+}
+
+// NewLayout builds a detector layout from a Go time layout. The timestamp
+// must be at the start of the line, optionally wrapped in square brackets.
+func NewLayout(layout string) (Layout, error) {
+	if layout == "" {
+		return Layout{}, fmt.Errorf("empty timestamp layout")
+	}
+
+	// Round-trip the reference time to catch layouts Go can't parse back
+	if _, err := time.Parse(layout, time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Format(layout)); err != nil {
+		return Layout{}, fmt.Errorf("invalid timestamp layout %q: %w", layout, err)
+	}
+
+	re, err := regexp.Compile(`^\[?(` + layoutToPattern(layout) + `)(?:\]|\b|$)`)
+	if err != nil {
+		return Layout{}, fmt.Errorf("invalid timestamp layout %q: %w", layout, err)
+	}
+
+	return Layout{
+		Name: layout,
+		re:   re,
+		parse: func(value string, loc *time.Location) (time.Time, error) {
+			return time.ParseInLocation(layout, value, loc)
+		},
+	}, nil
+}
+
+// mustLayout is NewLayout for the built-in list
+func mustLayout(layout string) Layout {
+	l, err := NewLayout(layout)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// apacheLayout matches the Apache/nginx access log timestamp, which follows
+// the client address rather than starting the line:
+// 127.0.0.1 - - [16/Oct/2026:10:00:00 +0900] "GET / HTTP/1.1" ...
+var apacheLayout = Layout{
+	Name: "apache",
+	re:   regexp.MustCompile(`\[(\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`),
+	parse: func(value string, loc *time.Location) (time.Time, error) {
+		return time.ParseInLocation("02/Jan/2006:15:04:05 -0700", value, loc)
+	},
+}
+
+// now is the clock syslog years are inferred from, replaced in tests
+var now = time.Now
+
+// syslogLayout matches the BSD syslog timestamp (RFC 3164), which has no year:
+// Oct 16 10:00:00 myhost app[123]: ...
+var syslogLayout = Layout{
+	Name: "syslog",
+	re:   regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)?)\b`),
+	parse: func(value string, loc *time.Location) (time.Time, error) {
+		t, err := time.ParseInLocation("Jan _2 15:04:05", value, loc)
+		if err != nil {
+			return t, err
+		}
+
+		// Assume the current year, unless that puts the entry in the future
+		// (e.g. a December line read in January)
+		now := now().In(loc)
+		t = t.AddDate(now.Year(), 0, 0)
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, nil
+	},
+}
+
+// minEpoch and maxEpoch bound the Unix timestamps accepted at the start of a
+// line, so that arbitrary leading numbers aren't mistaken for timestamps
+var (
+	minEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	maxEpoch = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+)
+
+// epochLayout matches Unix epoch seconds (optionally fractional) and milliseconds
+var epochLayout = Layout{
+	Name: "epoch",
+	re:   regexp.MustCompile(`^\[?(\d{13}|\d{10}(?:\.\d{1,9})?)(?:\]|\b|$)`),
+	parse: func(value string, loc *time.Location) (time.Time, error) {
+		var t time.Time
+		if len(value) == 13 {
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return t, err
+			}
+			t = time.UnixMilli(ms)
+		} else {
+			secs, frac, _ := strings.Cut(value, ".")
+			s, err := strconv.ParseInt(secs, 10, 64)
+			if err != nil {
+				return t, err
+			}
+			var ns int64
+			if frac != "" {
+				ns, _ = strconv.ParseInt((frac + "000000000")[:9], 10, 64)
+			}
+			t = time.Unix(s, ns)
+		}
+
+		if t.Unix() < minEpoch || t.Unix() >= maxEpoch {
+			return time.Time{}, fmt.Errorf("epoch %s out of range", value)
+		}
+		return t.UTC(), nil
+	},
+}
+
+// builtinLayouts are tried in order. Layouts with a zone offset come before
+// their zone-less counterparts so the offset isn't dropped.
+var builtinLayouts = []Layout{
+	mustLayout("2006-01-02T15:04:05Z07:00"),
+	mustLayout("2006-01-02 15:04:05Z07:00"),
+	mustLayout("2006-01-02T15:04:05Z0700"),
+	mustLayout("2006-01-02 15:04:05Z0700"),
+	mustLayout("2006-01-02 15:04:05 -0700"),
+	mustLayout("2006-01-02T15:04:05"),
+	mustLayout("2006-01-02 15:04:05"), // also Python's 2006-01-02 15:04:05,123
+	mustLayout("2006/01/02 15:04:05"), // Go's log package, nginx error log
+	mustLayout(time.RFC1123Z),
+	mustLayout(time.RFC1123),
+	mustLayout(time.ANSIC),
+	syslogLayout,
+	epochLayout,
+	apacheLayout,
+}
+
+// find locates the timestamp in line
+func (l *Layout) find(line string, loc *time.Location) (Match, bool) {
+	idx := l.re.FindStringSubmatchIndex(line)
+	if idx == nil {
+		return Match{}, false
+	}
+
+	t, err := l.parse(line[idx[2]:idx[3]], loc)
+	if err != nil {
+		return Match{}, false
+	}
+
+	return Match{
+		Time:   t,
+		Layout: l.Name,
+		Start:  idx[0],
+		End:    idx[1],
+	}, true
+}
@@ -0,0 +1,151 @@
+package timestamp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetect(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no zone database:", err)
+	}
+	d, err := NewDetector(tokyo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		line   string
+		layout string
+		want   time.Time
+		text   string // the matched text, brackets included
+	}{
+		{"2026-10-16T10:00:00Z started", "2006-01-02T15:04:05Z07:00",
+			time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC), "2026-10-16T10:00:00Z"},
+		{"2026-10-16T10:00:00.123+02:00 started", "2006-01-02T15:04:05Z07:00",
+			time.Date(2026, 10, 16, 8, 0, 0, 123e6, time.UTC), "2026-10-16T10:00:00.123+02:00"},
+		// Zone-less timestamps are read in the detector's zone
+		{"[2026-10-16 10:00:00] production.ERROR: x", "2006-01-02 15:04:05",
+			time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC), "[2026-10-16 10:00:00]"},
+		{"2026-10-16 10:00:00,123 INFO x", "2006-01-02 15:04:05",
+			time.Date(2026, 10, 16, 1, 0, 0, 123e6, time.UTC), "2026-10-16 10:00:00,123"},
+		{"2026/10/16 10:00:00 listening", "2006/01/02 15:04:05",
+			time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC), "2026/10/16 10:00:00"},
+		{"1760608800 tick", "epoch",
+			time.Date(2025, 10, 16, 10, 0, 0, 0, time.UTC), "1760608800"},
+		{"1760608800123 tick", "epoch",
+			time.Date(2025, 10, 16, 10, 0, 0, 123e6, time.UTC), "1760608800123"},
+		{`127.0.0.1 - - [16/Oct/2026:10:00:00 +0900] "GET / HTTP/1.1" 200`, "apache",
+			time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC), "[16/Oct/2026:10:00:00 +0900]"},
+	} {
+		m, ok := d.Detect(tc.line)
+		if !ok {
+			t.Errorf("%q: no timestamp found", tc.line)
+			continue
+		}
+		if m.Layout != tc.layout || !m.Time.Equal(tc.want) || tc.line[m.Start:m.End] != tc.text {
+			t.Errorf("%q: found %q as %v with %s, want %q as %v with %s",
+				tc.line, tc.line[m.Start:m.End], m.Time, m.Layout, tc.text, tc.want, tc.layout)
+		}
+	}
+
+	for _, line := range []string{
+		"no timestamp here",
+		"12345 items processed",   // too short for an epoch
+		"4102444800 out of range", // 2100-01-01
+		"user 2026-10-16 10:00:00 in the middle",
+	} {
+		if m, ok := d.Detect(line); ok {
+			t.Errorf("%q: found %s timestamp %v", line, m.Layout, m.Time)
+		}
+	}
+}
+
+func TestDetectorLearnsLayout(t *testing.T) {
+	// Lines with a year match the extra layout, and syslog too once it's
+	// learned: the detector settles on the layout most lines use
+	d, err := NewDetector(nil, []string{"Jan _2 15:04:05 2006"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syslog := "Oct 16 10:00:00 myhost app[1]: x"
+	year := "Oct 16 10:00:00 2026 myhost app[1]: x"
+
+	for i := 0; i < learnSamples-1; i++ {
+		line := syslog
+		if i%3 == 0 {
+			line = year
+		}
+		if _, ok := d.Detect(line); !ok {
+			t.Fatalf("%q: no timestamp found", line)
+		}
+		if layout := d.Layout(); layout != "" {
+			t.Fatalf("settled on %q after %d samples", layout, i+1)
+		}
+	}
+	d.Detect(syslog)
+	if layout := d.Layout(); layout != "syslog" {
+		t.Fatalf("Layout() = %q, want syslog", layout)
+	}
+
+	// The learned layout is tried first, and others are still found
+	m, ok := d.Detect(year)
+	if !ok || m.Layout != "syslog" {
+		t.Errorf("after learning, %q: %v %v", year, m, ok)
+	}
+	m, ok = d.Detect("2026-10-16T10:00:00Z x")
+	if !ok || m.Layout != "2006-01-02T15:04:05Z07:00" {
+		t.Errorf("after learning, ISO line: %v %v", m, ok)
+	}
+	if d.Layout() != "syslog" {
+		t.Errorf("Layout() changed to %q", d.Layout())
+	}
+}
+
+func TestNewDetectorRejectsBadLayouts(t *testing.T) {
+	for _, layout := range []string{"", "2006-13-02"} {
+		if _, err := NewDetector(nil, []string{layout}); err == nil {
+			t.Errorf("layout %q was accepted", layout)
+		}
+	}
+}
+
+func TestSyslogYear(t *testing.T) {
+	defer func(orig func() time.Time) { now = orig }(now)
+
+	for _, tc := range []struct {
+		now  time.Time
+		line string
+		want time.Time
+	}{
+		{time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), "Oct 16 10:00:00 host x",
+			time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)},
+		// A December line read in January is from last year
+		{time.Date(2026, 1, 2, 0, 30, 0, 0, time.UTC), "Dec 31 23:59:59 host x",
+			time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC)},
+		// Up to a day ahead is clock skew, not last year
+		{time.Date(2026, 1, 2, 0, 30, 0, 0, time.UTC), "Jan  2 20:00:00 host x",
+			time.Date(2026, 1, 2, 20, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 1, 2, 0, 30, 0, 0, time.UTC), "Jan  5 10:00:00 host x",
+			time.Date(2025, 1, 5, 10, 0, 0, 0, time.UTC)},
+		// Feb 29 in a leap year is kept when it's this year's
+		{time.Date(2028, 3, 1, 0, 0, 0, 0, time.UTC), "Feb 29 10:00:00 host x",
+			time.Date(2028, 2, 29, 10, 0, 0, 0, time.UTC)},
+	} {
+		now = func() time.Time { return tc.now }
+		d, err := NewDetector(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, ok := d.Detect(tc.line)
+		if !ok || m.Layout != "syslog" {
+			t.Errorf("%q: %v %v", tc.line, m, ok)
+			continue
+		}
+		if !m.Time.Equal(tc.want) {
+			t.Errorf("%q at %v: got %v, want %v", tc.line, tc.now, m.Time, tc.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package tui
+
+import "unicode/utf8"
+
+// Names of the special keys the viewer handles
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyPageUp    = "pgup"
+	keyPageDown  = "pgdn"
+	keyHome      = "home"
+	keyEnd       = "end"
+	keyEnter     = "enter"
+	keyTab       = "tab"
+	keyEscape    = "esc"
+	keyBackspace = "backspace"
+	keyCtrlC     = "ctrl+c"
+	keyCtrlU     = "ctrl+u"
+)
+
+// key is a key press: a printable rune, or a named key
+type key struct {
+	r    rune
+	name string
+}
+
+// escapeKeys names the escape sequences of special keys, without the
+// leading ESC. Terminals send either form of the arrows and Home/End.
+var escapeKeys = map[string]string{
+	"[A": keyUp, "OA": keyUp,
+	"[B": keyDown, "OB": keyDown,
+	"[H": keyHome, "OH": keyHome, "[1~": keyHome, "[7~": keyHome,
+	"[F": keyEnd, "OF": keyEnd, "[4~": keyEnd, "[8~": keyEnd,
+	"[5~": keyPageUp,
+	"[6~": keyPageDown,
+}
+
+// decodeKeys splits terminal input into key presses. Unknown escape
+// sequences decode to a key without a name.
+func decodeKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		k, n := decodeKey(b)
+		keys = append(keys, k)
+		b = b[n:]
+	}
+	return keys
+}
+
+// decodeKey decodes the key press at the start of b and its length
+func decodeKey(b []byte) (key, int) {
+	switch c := b[0]; {
+	case c == 0x1b:
+		// A lone ESC is the Escape key; otherwise a CSI or SS3 sequence
+		// runs up to a final byte in @..~
+		if len(b) > 1 && (b[1] == '[' || b[1] == 'O') {
+			for i := 2; i < len(b); i++ {
+				if b[i] >= 0x40 && b[i] <= 0x7e {
+					return key{name: escapeKeys[string(b[1:i+1])]}, i + 1
+				}
+			}
+			return key{}, len(b)
+		}
+		return key{name: keyEscape}, 1
+	case c == '\r' || c == '\n':
+		return key{name: keyEnter}, 1
+	case c == '\t':
+		return key{name: keyTab}, 1
+	case c == 0x7f || c == 0x08:
+		return key{name: keyBackspace}, 1
+	case c == 0x03:
+		return key{name: keyCtrlC}, 1
+	case c == 0x15:
+		return key{name: keyCtrlU}, 1
+	case c < 0x20:
+		return key{}, 1
+	}
+
+	r, n := utf8.DecodeRune(b)
+	return key{r: r}, n
+}
@@ -0,0 +1,463 @@
+// Package tui is a full-screen terminal log viewer, for SSH sessions where
+// the web dashboard can't be reached. It's fed by a models.LogCollector like
+// the server, and offers scrolling, incremental search, level toggles,
+// folding of multiline entries and pausing.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/monstarlab/shepai/internal/models"
+	"github.com/monstarlab/shepai/internal/query"
+	"golang.org/x/term"
+)
+
+const (
+	// maxEvents is how many events are kept; the oldest are dropped in
+	// chunks of a tenth of it
+	maxEvents = 10000
+
+	// redrawInterval batches redraws while events stream in, and is how
+	// often a change of terminal size is noticed
+	redrawInterval = 50 * time.Millisecond
+
+	// statusTimeout is how long an info status, such as a reconnect, stays
+	// in the header; warnings and errors stay until the next status
+	statusTimeout = 5 * time.Second
+)
+
+// levelToggles are the level filters, toggled by their keys. Success and
+// unknown levels share the last one.
+var levelToggles = []struct {
+	key    rune
+	name   string
+	levels []string
+}{
+	{'1', "error", []string{query.LevelError}},
+	{'2', "warn", []string{query.LevelWarning}},
+	{'3', "info", []string{query.LevelInfo}},
+	{'4', "debug", []string{query.LevelDebug}},
+	{'5', "other", []string{query.LevelSuccess, query.LevelDefault}},
+}
+
+// Options configures the viewer
+type Options struct {
+	// Filter is the initial search query
+	Filter string
+}
+
+// entry is a kept event and its level
+type entry struct {
+	event models.LogEvent
+	level string
+}
+
+// viewer is the state of the log viewer
+type viewer struct {
+	source        string
+	width, height int
+
+	entries []entry // oldest first
+	nextSeq int64
+
+	// visible are the indexes of the entries passing the filters; sel is
+	// the selected one and top the first shown, both indexes into visible
+	visible []int
+	sel     int
+	top     int
+
+	// follow keeps the newest entry selected as events arrive
+	follow bool
+
+	filter    *query.Query
+	search    string // query being typed or applied
+	searching bool
+	searchErr string
+	hidden    map[string]bool // levels toggled off
+
+	// Multiline entries are folded unless unfoldAll is set; toggled
+	// flips an entry, by sequence ID, from that default
+	unfoldAll bool
+	toggled   map[int64]bool
+
+	// Events arriving while paused wait in pending
+	paused  bool
+	pending []models.LogEvent
+
+	// status is the collector's last status event, shown in the header;
+	// nil when there's none to show
+	status *models.StatusEvent
+
+	changed bool // events arrived since the last redraw
+	quit    bool
+}
+
+func newViewer(source string) *viewer {
+	return &viewer{
+		source:  source,
+		follow:  true,
+		hidden:  make(map[string]bool),
+		toggled: make(map[int64]bool),
+	}
+}
+
+// Run shows the viewer on the terminal until the user quits, feeding it
+// the collector's snapshot and live events
+func Run(c models.LogCollector, opts Options) error {
+	inFd, outFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return fmt.Errorf("shepai tui needs a terminal; use --no-ui to print logs to a pipe")
+	}
+
+	v := newViewer(c.GetSourceName())
+	if opts.Filter != "" {
+		v.search = opts.Filter
+		if err := v.applySearch(); err != nil {
+			return fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+
+	snapshot, err := c.GetSnapshot()
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	for _, event := range snapshot {
+		v.add(event)
+	}
+
+	statuses := make(chan models.StatusEvent, 16)
+	if n, ok := c.(models.StatusNotifier); ok {
+		n.NotifyStatus(statuses)
+	}
+
+	events := make(chan models.LogEvent, 100)
+	if err := c.Start(events); err != nil {
+		return fmt.Errorf("failed to start collector: %w", err)
+	}
+	defer c.Stop()
+
+	oldState, err := term.MakeRaw(inFd)
+	if err != nil {
+		return fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer term.Restore(inFd, oldState)
+
+	// Log output would scribble over the screen
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// Alternate screen, without a cursor
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	defer os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+
+	input := make(chan []byte)
+	go readInput(os.Stdin, input)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(redrawInterval)
+	defer ticker.Stop()
+
+	dirty := true
+	for !v.quit {
+		if w, h, err := term.GetSize(outFd); err == nil && (w != v.width || h != v.height) {
+			v.width, v.height = w, h
+			dirty = true
+		}
+		if dirty {
+			v.scrollToSelection()
+			os.Stdout.Write(v.render())
+			dirty = false
+		}
+
+		select {
+		case event := <-events:
+			v.add(event)
+			// Redraw on the next tick, with whatever else arrived
+			for pending := true; pending; {
+				select {
+				case event := <-events:
+					v.add(event)
+				default:
+					pending = false
+				}
+			}
+		case data, ok := <-input:
+			if !ok {
+				return nil
+			}
+			for _, k := range decodeKeys(data) {
+				v.handleKey(k)
+			}
+			dirty = true
+		case status := <-statuses:
+			v.status = &status
+			dirty = true
+		case <-ticker.C:
+			if v.status != nil && v.status.Severity == models.SeverityInfo && time.Since(v.status.Time) > statusTimeout {
+				v.status = nil
+				dirty = true
+			}
+			dirty = dirty || v.changed
+			v.changed = false
+		case <-sigChan:
+			return nil
+		}
+	}
+	return nil
+}
+
+// readInput sends chunks read from the terminal until it fails
+func readInput(r io.Reader, input chan<- []byte) {
+	defer close(input)
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		input <- append([]byte(nil), buf[:n]...)
+	}
+}
+
+// add keeps an event, or holds it while paused
+func (v *viewer) add(event models.LogEvent) {
+	if v.paused {
+		v.pending = append(v.pending, event)
+		if len(v.pending) > maxEvents {
+			v.pending = v.pending[len(v.pending)-maxEvents:]
+		}
+		v.changed = true
+		return
+	}
+
+	event.Seq = v.nextSeq
+	v.nextSeq++
+
+	e := entry{event: event, level: query.Level(event.Level, event.Message)}
+	v.entries = append(v.entries, e)
+	if v.shows(e) {
+		v.visible = append(v.visible, len(v.entries)-1)
+		if v.follow || v.sel < 0 {
+			v.sel = len(v.visible) - 1
+		}
+	}
+
+	if len(v.entries) > maxEvents+maxEvents/10 {
+		// visible indexes the entries before they're dropped, so note the
+		// selection first
+		selSeq := v.selectedSeq()
+		v.entries = append([]entry(nil), v.entries[len(v.entries)-maxEvents:]...)
+		v.rebuild(selSeq)
+	}
+	v.changed = true
+}
+
+// shows reports whether an entry passes the level toggles and search
+func (v *viewer) shows(e entry) bool {
+	return !v.hidden[e.level] && (v.filter == nil || v.filter.Match(e.event))
+}
+
+// refilter rebuilds the visible entries, keeping the selection on the
+// same event, or the next one shown
+func (v *viewer) refilter() {
+	v.rebuild(v.selectedSeq())
+}
+
+// selectedSeq is the sequence ID of the selected entry, or -1 without one
+func (v *viewer) selectedSeq() int64 {
+	if v.sel >= 0 && v.sel < len(v.visible) {
+		return v.entries[v.visible[v.sel]].event.Seq
+	}
+	return -1
+}
+
+// rebuild rebuilds the visible entries from the kept ones, selecting the
+// event with sequence ID selSeq, or the next one shown
+func (v *viewer) rebuild(selSeq int64) {
+	v.visible = v.visible[:0]
+	for i, e := range v.entries {
+		if v.shows(e) {
+			v.visible = append(v.visible, i)
+		}
+	}
+
+	if v.follow || selSeq < 0 {
+		v.sel = len(v.visible) - 1
+	} else {
+		v.sel = sort.Search(len(v.visible), func(i int) bool {
+			return v.entries[v.visible[i]].event.Seq >= selSeq
+		})
+		v.sel = min(v.sel, len(v.visible)-1)
+	}
+	v.follow = v.sel == len(v.visible)-1
+	v.top = max(0, min(v.top, v.sel))
+}
+
+// applySearch parses the search as a query and filters by it
+func (v *viewer) applySearch() error {
+	q, err := query.Parse(v.search)
+	if err != nil {
+		return err
+	}
+	v.filter = nil
+	if !q.Empty() {
+		v.filter = q
+	}
+	v.refilter()
+	return nil
+}
+
+// setPaused pauses or resumes the view; resuming shows the held events
+func (v *viewer) setPaused(paused bool) {
+	if paused == v.paused {
+		return
+	}
+	v.paused = paused
+	if !paused {
+		pending := v.pending
+		v.pending = nil
+		for _, event := range pending {
+			v.add(event)
+		}
+	}
+}
+
+// handleKey acts on a key press
+func (v *viewer) handleKey(k key) {
+	if v.searching {
+		v.handleSearchKey(k)
+		return
+	}
+
+	page := max(1, v.listHeight()-1)
+	switch {
+	case k.name == keyCtrlC || k.r == 'q':
+		v.quit = true
+	case k.name == keyUp || k.r == 'k':
+		v.moveSelection(-1)
+	case k.name == keyDown || k.r == 'j':
+		v.moveSelection(1)
+	case k.name == keyPageUp || k.r == 'b':
+		v.moveSelection(-page)
+	case k.name == keyPageDown || k.r == ' ':
+		v.moveSelection(page)
+	case k.name == keyHome || k.r == 'g':
+		v.moveSelection(-len(v.visible))
+	case k.name == keyEnd || k.r == 'G':
+		v.moveSelection(len(v.visible))
+	case k.r == '/':
+		v.searching = true
+	case k.name == keyEscape:
+		// Clear the search
+		v.search, v.searchErr = "", ""
+		v.applySearch()
+	case k.name == keyEnter || k.name == keyTab:
+		if v.sel >= 0 && v.sel < len(v.visible) {
+			seq := v.entries[v.visible[v.sel]].event.Seq
+			v.toggled[seq] = !v.toggled[seq]
+		}
+	case k.r == 'z':
+		v.unfoldAll = !v.unfoldAll
+		clear(v.toggled)
+	case k.r == 'p':
+		v.setPaused(!v.paused)
+	default:
+		for _, t := range levelToggles {
+			if k.r == t.key {
+				for _, level := range t.levels {
+					v.hidden[level] = !v.hidden[level]
+				}
+				v.refilter()
+			}
+		}
+	}
+}
+
+// handleSearchKey edits the search, filtering as it's typed. Enter keeps
+// the search and Escape clears it.
+func (v *viewer) handleSearchKey(k key) {
+	switch {
+	case k.name == keyEnter:
+		v.searching = false
+		return
+	case k.name == keyEscape:
+		v.searching = false
+		v.search = ""
+	case k.name == keyCtrlC:
+		v.quit = true
+		return
+	case k.name == keyBackspace:
+		if r := []rune(v.search); len(r) > 0 {
+			v.search = string(r[:len(r)-1])
+		}
+	case k.name == keyCtrlU:
+		v.search = ""
+	case k.r >= ' ':
+		v.search += string(k.r)
+	default:
+		return
+	}
+
+	// An incomplete query keeps the last filter until it parses
+	v.searchErr = ""
+	if err := v.applySearch(); err != nil {
+		v.searchErr = err.Error()
+	}
+}
+
+// moveSelection moves the selection by delta entries. Reaching the newest
+// entry follows new events; moving away stops following.
+func (v *viewer) moveSelection(delta int) {
+	if len(v.visible) == 0 {
+		return
+	}
+	v.sel = max(0, min(len(v.visible)-1, v.sel+delta))
+	v.follow = v.sel == len(v.visible)-1
+}
+
+// folded reports whether an entry's continuation lines are hidden
+func (v *viewer) folded(e entry) bool {
+	return v.unfoldAll == v.toggled[e.event.Seq]
+}
+
+// rows returns how many rows an entry takes
+func (v *viewer) rows(e entry) int {
+	if v.folded(e) {
+		return 1
+	}
+	return 1 + len(e.event.Details)
+}
+
+// listHeight is the number of rows for entries, between the header and
+// the footer
+func (v *viewer) listHeight() int {
+	return max(1, v.height-2)
+}
+
+// scrollToSelection scrolls so the selected entry is shown
+func (v *viewer) scrollToSelection() {
+	if v.sel < v.top {
+		v.top = v.sel
+	}
+	for v.top < v.sel {
+		rows := 0
+		for i := v.top; i <= v.sel; i++ {
+			rows += v.rows(v.entries[v.visible[i]])
+		}
+		if rows <= v.listHeight() {
+			break
+		}
+		v.top++
+	}
+	v.top = max(0, v.top)
+}
@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/monstarlab/shepai/internal/models"
+)
+
+func TestAddDropsOldest(t *testing.T) {
+	total := maxEvents + maxEvents/10 + 1
+
+	tests := []struct {
+		name    string
+		search  string // filter applied before the events arrive
+		back    int    // rows moved up from the newest after the first events
+		want    int64  // sequence ID selected at the end
+		follows bool
+	}{
+		{"following", "", 0, int64(total) - 1, true},
+		{"following filtered", "even", 0, int64(total) - 1, true},
+		{"row kept", "", 10, int64(total/2) - 11, false},
+		{"row kept filtered", "even", 10, int64(total/2) - 22, false},
+		// The selected event is dropped; the oldest kept one is selected
+		{"row dropped", "", total/2 - 1, int64(total - maxEvents), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newViewer("test")
+			if tt.search != "" {
+				v.search = tt.search
+				if err := v.applySearch(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			event := func(i int) models.LogEvent {
+				word := "odd"
+				if i%2 == 0 {
+					word = "even"
+				}
+				return models.LogEvent{Message: fmt.Sprintf("line %d %s", i, word)}
+			}
+			for i := 0; i < total/2; i++ {
+				v.add(event(i))
+			}
+			v.moveSelection(-tt.back)
+			for i := total / 2; i < total; i++ {
+				v.add(event(i))
+			}
+
+			if len(v.entries) > maxEvents+maxEvents/10 {
+				t.Errorf("kept %d entries", len(v.entries))
+			}
+			for _, i := range v.visible {
+				if i >= len(v.entries) {
+					t.Fatalf("visible index %d of %d entries", i, len(v.entries))
+				}
+			}
+			if got := v.selectedSeq(); got != tt.want {
+				t.Errorf("selected %d, want %d", got, tt.want)
+			}
+			if v.follow != tt.follows {
+				t.Errorf("follow = %v, want %v", v.follow, tt.follows)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/monstarlab/shepai/internal/printer"
+	"golang.org/x/text/width"
+)
+
+// SGR escape sequences used by the viewer
+const (
+	sgrReset   = "\x1b[0m"
+	sgrBold    = "\x1b[1m"
+	sgrDim     = "\x1b[2m"
+	sgrReverse = "\x1b[7m"
+	sgrRed     = "\x1b[31m"
+)
+
+const (
+	// timeLayout is how entry times are shown; the date is in the header
+	timeLayout = "15:04:05"
+
+	// prefixCells is the width of the marker, time and level before
+	// each message
+	prefixCells = 2 + len(timeLayout) + 1 + 5 + 1
+)
+
+// render draws the whole screen: a header, the entries from top, and a
+// footer with the search or key help
+func (v *viewer) render() []byte {
+	var b bytes.Buffer
+	b.WriteString("\x1b[H")
+
+	v.renderHeader(&b)
+
+	row := 0
+	for i := v.top; i < len(v.visible) && row < v.listHeight(); i++ {
+		e := v.entries[v.visible[i]]
+		v.line(&b, 2+row, v.entryLine(e, i == v.sel))
+		row++
+
+		if v.folded(e) {
+			continue
+		}
+		for _, detail := range e.event.Details {
+			if row >= v.listHeight() {
+				break
+			}
+			indent := strings.Repeat(" ", prefixCells)
+			v.line(&b, 2+row, indent+sgrDim+"│ "+fit(detail, v.width-prefixCells-2)+sgrReset)
+			row++
+		}
+	}
+	for ; row < v.listHeight(); row++ {
+		v.line(&b, 2+row, "")
+	}
+
+	v.renderFooter(&b)
+	return b.Bytes()
+}
+
+// line writes a row, clearing what was there
+func (v *viewer) line(b *bytes.Buffer, row int, content string) {
+	fmt.Fprintf(b, "\x1b[%d;1H%s\x1b[K", row, content)
+}
+
+// renderHeader writes the source, the counts and the follow or pause
+// state. A status of the source, such as a missing file, replaces the date.
+func (v *viewer) renderHeader(b *bytes.Buffer) {
+	left := " shepai · " + v.source
+	if v.status != nil {
+		left += " · " + v.status.Message
+	} else if n := len(v.entries); n > 0 {
+		left += " · " + v.entries[n-1].event.Timestamp.Format("2006-01-02")
+	}
+
+	right := fmt.Sprintf("%d of %d events", len(v.visible), len(v.entries))
+	switch {
+	case v.paused:
+		right += fmt.Sprintf(" · PAUSED, %d new", len(v.pending))
+	case v.follow:
+		right += " · following"
+	}
+	right += " "
+
+	right = fit(right, v.width)
+	left = fit(left, v.width-cells(right))
+	pad := max(0, v.width-cells(left)-cells(right))
+	v.line(b, 1, sgrReverse+left+strings.Repeat(" ", pad)+right+sgrReset)
+}
+
+// renderFooter writes the search being typed, or the keys and the state
+// of the level toggles, as much as fits
+func (v *viewer) renderFooter(b *bytes.Buffer) {
+	row := v.height
+
+	if v.searching {
+		text := "/" + fit(v.search, v.width-2) + sgrReverse + " " + sgrReset
+		if room := v.width - cells(v.search) - 4; v.searchErr != "" && room > 0 {
+			text += "  " + sgrRed + fit(v.searchErr, room) + sgrReset
+		}
+		v.line(b, row, text)
+		return
+	}
+
+	type segment struct{ text, sgr string }
+	var segments []segment
+	if v.search != "" {
+		segments = append(segments, segment{"/" + fit(v.search, v.width/3) + "  ", sgrBold})
+	}
+	for _, t := range levelToggles {
+		sgr := sgrDim
+		if !v.hidden[t.levels[0]] {
+			if _, sgr = printer.LevelTag(t.levels[0]); sgr == "" {
+				sgr = sgrBold
+			}
+		}
+		segments = append(segments, segment{fmt.Sprintf("%c %s ", t.key, t.name), sgr})
+	}
+	segments = append(segments, segment{" / search  ⏎ fold  z fold all  p pause  g/G top/end  q quit", sgrDim})
+
+	var text strings.Builder
+	used := 0
+	for _, seg := range segments {
+		if used+cells(seg.text) > v.width {
+			break
+		}
+		text.WriteString(seg.sgr + seg.text + sgrReset)
+		used += cells(seg.text)
+	}
+	v.line(b, row, text.String())
+}
+
+// entryLine formats an entry's first row: a selection marker, its time and
+// level, and its message, with the count of folded lines
+func (v *viewer) entryLine(e entry, selected bool) string {
+	marker := "  "
+	if selected {
+		marker = sgrBold + "▌ " + sgrReset
+	}
+
+	tag, color := printer.LevelTag(e.level)
+	prefix := marker + sgrDim + e.event.Timestamp.Format(timeLayout) + sgrReset + " " +
+		color + fmt.Sprintf("%-5s", tag) + sgrReset + " "
+	room := v.width - prefixCells
+
+	var folded string
+	if n := len(e.event.Details); n > 0 && v.folded(e) {
+		folded = fmt.Sprintf(" [+%d]", n)
+		room -= len(folded)
+	}
+
+	message := fit(e.event.Message, room)
+	if selected {
+		message = sgrBold + message + sgrReset
+	}
+	return prefix + message + sgrDim + folded + sgrReset
+}
+
+// fit makes s printable and cuts it to n terminal cells, marking the cut
+func fit(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case r < ' ' || r == 0x7f:
+			return -1
+		}
+		return r
+	}, s)
+	if cells(s) <= n {
+		return s
+	}
+
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := runeCells(r)
+		if used+w > n-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	b.WriteRune('…')
+	return b.String()
+}
+
+// cells returns the width of s in terminal cells, ignoring escape sequences
+func cells(s string) int {
+	n := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case r == 0x1b:
+			inEscape = true
+		case inEscape:
+			if r >= 0x40 && r <= 0x7e && r != '[' {
+				inEscape = false
+			}
+		default:
+			n += runeCells(r)
+		}
+	}
+	return n
+}
+
+// runeCells returns the cells a rune takes: two for wide East Asian runes
+func runeCells(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}